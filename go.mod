@@ -1,6 +1,6 @@
 module github.com/88250/lute-pdf
 
-go 1.14
+go 1.16
 
 require (
 	github.com/88250/gulu v1.1.73
@@ -8,6 +8,9 @@ require (
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/phpdave11/gofpdi v1.0.13 // indirect
 	github.com/signintech/gopdf v0.10.1
+	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c
+	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef
+	golang.org/x/image v0.0.0-20211028202545-6944b10bf410
 )
 
 //replace github.com/88250/lute => D:\gogogo\src\github.com\88250\lute
@@ -12,13 +12,20 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"github.com/88250/lute/render"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
 	"github.com/88250/lute/parse"
 )
 
@@ -28,6 +35,11 @@ func init() {
 	logger = gulu.Log.NewLogger(os.Stdout)
 }
 
+// imgSizeSuffixDest 匹配地址后带 =WxH 尺寸后缀的图片以及紧随其后的行级属性，比如 ![](a.png =300x){: align="center"}。
+//
+// CommonMark 不支持这种写法，解析后是普通文本，代码块和行内代码中的内容不是文本节点，不会被匹配。
+var imgSizeSuffixDest = regexp.MustCompile(`!\[([^\]]*)\]\((\S+)\s+=(\d*x\d*)\)(\{:[^}]*\})?`)
+
 func main() {
 	argMdPath := flag.String("mdPath", "D:/88250/lute-pdf/sample.md", "待转换的 Markdown 文件路径")
 	argSavePath := flag.String("savePath", "D:/88250/lute-pdf/sample.pdf", "转换后 PDF 的保存路径")
@@ -48,6 +60,45 @@ func main() {
 	argCoverLogoTitle := flag.String("coverLogoTitle", "B3log 开源", "封面 - 图标标题")
 	argCoverLogoTitleLink := flag.String("coverLogoTitleLink", "https://b3log.org", "封面 - 图标标题链接")
 
+	argBlockquoteBorderColor := flag.String("blockquoteBorderColor", "#dfe2e5", "引述 - 左边框颜色，为空时不绘制左边框")
+	argBlockquoteBackground := flag.String("blockquoteBackground", "", "引述 - 背景色，为空时不填充背景")
+	argBlockquotePadding := flag.Float64("blockquotePadding", 10, "引述 - 内边距")
+	argListBullets := flag.String("listBullets", "●,○,■", "列表 - 无序列表各嵌套层级的符号，使用逗号分隔")
+	argListNumberStyles := flag.String("listNumberStyles", "1,a,i,A", "列表 - 有序列表各嵌套层级的编号样式（1、a、A、i、I），使用逗号分隔")
+	argTaskListCheckedGray := flag.Bool("taskListCheckedGray", false, "任务列表 - 已完成项是否使用灰色文本")
+	argTaskListCheckedStrike := flag.Bool("taskListCheckedStrike", false, "任务列表 - 已完成项是否添加删除线")
+	argTaskListFormField := flag.Bool("taskListFormField", false, "任务列表 - 复选框是否渲染为可交互的表单域")
+	argImageDPI := flag.Float64("imageDPI", 128, "图片 - 没有记录分辨率时使用的分辨率（每英寸像素数）")
+	argImageMaxScale := flag.Float64("imageMaxScale", 1, "图片 - 没有指定尺寸的图片的最大放大倍数，图片会等比缩放到内容区域以内，1 表示不放大")
+	argImageMaxDPI := flag.Float64("imageMaxDPI", 0, "图片 - 输出图片的最大分辨率（按照打印尺寸计算每英寸像素数），超过时降采样，0 表示不降采样")
+	argImageJPEGQuality := flag.Int("imageJPEGQuality", 0, "图片 - 不透明照片重新压缩为 JPEG 的质量（1-100），0 表示不重新压缩")
+	argImageGrayscale := flag.Bool("imageGrayscale", false, "图片 - 是否将图片转换为灰度，用于黑白打印")
+	argImageCaption := flag.Bool("imageCaption", false, "图片 - 是否在独占一个段落的图片下方输出带编号的题注（取自替代文本或者标题）")
+	argFigureLabel := flag.String("figureLabel", "Figure", "图片 - 题注编号前缀")
+	argListOfFigures := flag.Bool("listOfFigures", false, "图片 - 是否在正文前输出图片目录，需要开启题注")
+	argListOfFiguresTitle := flag.String("listOfFiguresTitle", "List of Figures", "图片 - 图片目录标题")
+	argImageRoot := flag.String("imageRoot", "", "图片 - 本地图片相对路径的基准目录，为空时使用 Markdown 文件所在目录")
+	argImageSandbox := flag.Bool("imageSandbox", false, "图片 - 是否只允许加载图片基准目录内的本地图片，禁止绝对路径和跳出该目录的相对路径、符号链接")
+	argImageStrict := flag.Bool("imageStrict", false, "图片 - 是否在有图片加载失败时报错退出，不生成 PDF")
+	argImageBundle := flag.String("imageBundle", "", "图片 - 图片资源包（zip）路径，相对路径的图片先在资源包中查找，找不到时再按照本地路径加载")
+	argImageRewriteConfPath := flag.String("imageRewriteConfPath", "", "图片 - 网络图片地址改写规则配置文件路径（JSON），为空时使用七牛云图片处理预设")
+	argImageWorkers := flag.Int("imageWorkers", 4, "图片 - 并发下载网络图片的数量")
+	argImageCacheDir := flag.String("imageCacheDir", defaultImgCacheDir(), "图片 - 网络图片缓存目录，再次转换时通过 ETag/Last-Modified 验证缓存，为空时不缓存")
+	argNetOffline := flag.Bool("netOffline", false, "网络 - 离线模式，不下载网络图片，只使用已缓存的图片")
+	argNetAllowHosts := flag.String("netAllowHosts", "", "网络 - 允许下载图片的主机名（包括子域名，*. 开头时只匹配子域名），使用逗号分隔，为空时不限制")
+	argNetDenyHosts := flag.String("netDenyHosts", "", "网络 - 禁止下载图片的主机名（包括子域名，*. 开头时只匹配子域名），使用逗号分隔")
+	argNetBlockPrivate := flag.Bool("netBlockPrivate", false, "网络 - 是否禁止访问回环、内网和链路本地等私有地址")
+	argNetProxy := flag.String("netProxy", "", "网络 - 代理地址，为空时使用 HTTP_PROXY、HTTPS_PROXY 环境变量")
+	argNetMaxSize := flag.Int64("netMaxSize", 20<<20, "网络 - 单个图片的最大字节数，小于等于 0 时不限制")
+	argNetCheckContentType := flag.Bool("netCheckContentType", true, "网络 - 是否检查响应的 Content-Type 为图片")
+	argNetTimeout := flag.Duration("netTimeout", 30*time.Second, "网络 - 单次请求的超时时间")
+	argNetRetries := flag.Int("netRetries", 1, "网络 - 网络错误、服务端错误或者限流时的重试次数")
+	argEmojiDir := flag.String("emojiDir", "D:/88250/lute-pdf/emojis", "Emoji - 图片目录，图片按照 Twemoji 的规则以码点命名，比如 1f600.png")
+	argEmojiCDN := flag.String("emojiCDN", "https://cdn.jsdelivr.net/gh/twitter/twemoji@14.0.2/assets/72x72", "Emoji - 图片地址前缀，图片目录中没有对应图片时从这里下载，为空时不下载")
+	argEmojiSite := flag.String("emojiSite", "https://cdn.jsdelivr.net/npm/vditor/dist/images/emoji", "Emoji - 图片 Emoji（比如 :huaji:）的地址前缀")
+	argMathAutoNumber := flag.Bool("mathAutoNumber", false, "数学公式 - 是否为所有公式块自动编号，否则只为带 \\label{} 的公式块编号")
+	argCalloutsConfPath := flag.String("calloutsConfPath", "", "提示块 - 样式配置文件路径（JSON），可覆盖默认类型或添加自定义类型")
+
 	flag.Parse()
 
 	mdPath := trimQuote(*argMdPath)
@@ -70,21 +121,38 @@ func main() {
 	coverLicense := trimQuote(*argCoverLicense)
 	coverLicenseLink := trimQuote(*argCoverLicenseLink)
 	coverLogoLink := trimQuote(*argCoverLogoLink)
+	if gulu.File.IsExist(coverLogoLink) {
+		// 封面图标是命令行参数，本地路径相对于当前工作目录，而不是 Markdown 文件所在目录
+		coverLogoLink, _ = filepath.Abs(coverLogoLink)
+	}
 	coverLogoTitle := trimQuote(*argCoverLogoTitle)
 	coverLogoTitleLink := trimQuote(*argCoverLogoTitleLink)
 
+	blockquoteBorderColor := parseColor(trimQuote(*argBlockquoteBorderColor))
+	blockquoteBackground := parseColor(trimQuote(*argBlockquoteBackground))
+	calloutsConfPath := trimQuote(*argCalloutsConfPath)
+
 	parseOptions := parse.NewOptions()
+	parseOptions.KramdownSpanIAL = true
+	parseOptions.EmojiSite = strings.TrimSuffix(trimQuote(*argEmojiSite), "/")
 	markdown, err := ioutil.ReadFile(mdPath)
 	if nil != err {
 		logger.Fatal(err)
 	}
 
 	markdown = bytes.ReplaceAll(markdown, []byte("\t"), []byte("    "))
-	for emojiUnicode, emojiAlias := range parseOptions.EmojiAlias {
-		markdown = bytes.ReplaceAll(markdown, []byte(emojiUnicode), []byte(":"+emojiAlias+":"))
+	// 先替换较长的 Emoji，避免组合 Emoji 被拆开
+	var emojis []string
+	for emojiUnicode := range parseOptions.EmojiAlias {
+		emojis = append(emojis, emojiUnicode)
+	}
+	sort.Slice(emojis, func(i, j int) bool { return len(emojis[i]) > len(emojis[j]) })
+	for _, emojiUnicode := range emojis {
+		markdown = bytes.ReplaceAll(markdown, []byte(emojiUnicode), []byte(":"+parseOptions.EmojiAlias[emojiUnicode]+":"))
 	}
 
 	tree := parse.Parse("", markdown, parseOptions)
+	parseImgSizeSuffix(tree, parseOptions)
 
 	renderOptions := render.NewOptions()
 	renderer := NewPdfRenderer(tree, renderOptions, regularFontPath, boldFontPath, italicFontPath)
@@ -105,14 +173,195 @@ func main() {
 		LogoTitle:     coverLogoTitle,
 		LogoTitleLink: coverLogoTitleLink,
 	}
+	renderer.BlockquoteStyle.BorderColor = blockquoteBorderColor
+	renderer.BlockquoteStyle.Background = blockquoteBackground
+	renderer.BlockquoteStyle.Padding = *argBlockquotePadding
+	renderer.ListBullets = strings.Split(trimQuote(*argListBullets), ",")
+	renderer.ListNumberStyles = strings.Split(trimQuote(*argListNumberStyles), ",")
+	renderer.TaskListCheckedGray = *argTaskListCheckedGray
+	renderer.TaskListCheckedStrike = *argTaskListCheckedStrike
+	renderer.TaskListFormField = *argTaskListFormField
+	renderer.MathAutoNumber = *argMathAutoNumber
+	renderer.ImageDPI = *argImageDPI
+	renderer.ImageMaxScale = *argImageMaxScale
+	renderer.ImageMaxDPI = *argImageMaxDPI
+	renderer.ImageJPEGQuality = *argImageJPEGQuality
+	renderer.ImageGrayscale = *argImageGrayscale
+	renderer.ImageCaption = *argImageCaption
+	renderer.FigureLabel = trimQuote(*argFigureLabel)
+	renderer.ListOfFigures = *argListOfFigures
+	renderer.ListOfFiguresTitle = trimQuote(*argListOfFiguresTitle)
+	renderer.ImageRoot = trimQuote(*argImageRoot)
+	if "" == renderer.ImageRoot {
+		renderer.ImageRoot = filepath.Dir(mdPath)
+	}
+	renderer.ImageSandbox = *argImageSandbox
+	if imageBundle := trimQuote(*argImageBundle); "" != imageBundle {
+		bundle, err := NewZipImageResolver(imageBundle, "")
+		if nil != err {
+			logger.Fatalf("load image bundle [%s] failed: %s", imageBundle, err)
+		}
+		renderer.ImageResolver = ImageResolvers{bundle, renderer}
+	}
+	if imageRewriteConfPath := trimQuote(*argImageRewriteConfPath); "" != imageRewriteConfPath {
+		renderer.ImageRewriteRules = loadImageRewriteRules(imageRewriteConfPath)
+	}
+	renderer.ImageWorkers = *argImageWorkers
+	renderer.ImageCacheDir = trimQuote(*argImageCacheDir)
+	renderer.NetPolicy = &PdfNetPolicy{
+		Offline:          *argNetOffline,
+		AllowHosts:       splitList(trimQuote(*argNetAllowHosts)),
+		DenyHosts:        splitList(trimQuote(*argNetDenyHosts)),
+		BlockPrivate:     *argNetBlockPrivate,
+		Proxy:            trimQuote(*argNetProxy),
+		MaxSize:          *argNetMaxSize,
+		CheckContentType: *argNetCheckContentType,
+		Timeout:          *argNetTimeout,
+		Retries:          *argNetRetries,
+	}
+	renderer.EmojiDir = trimQuote(*argEmojiDir)
+	renderer.EmojiCDN = trimQuote(*argEmojiCDN)
+	if "" != calloutsConfPath {
+		loadCallouts(calloutsConfPath, renderer.Callouts)
+	}
+	renderer.PrefetchImages()
 	renderer.RenderCover()
 
 	renderer.Render()
+	if report := renderer.ImageFailureReport(); *argImageStrict && "" != report {
+		// 不生成 PDF 时也需要等待后台下载结束并删除临时的图片缓存目录，然后再报错退出
+		renderer.cleanImgCache()
+		logger.Error(report)
+		os.Exit(1)
+	}
 	renderer.Save(savePath)
 
 	logger.Info("completed")
 }
 
+// parseImgSizeSuffix 将语法树 tree 文本节点中带 =WxH 尺寸后缀的图片解析为图片节点，尺寸后缀作为标题，比如 ![](a.png =300x200) 按照 ![](a.png "=300x200") 解析。
+func parseImgSizeSuffix(tree *parse.Tree, options *parse.Options) {
+	var texts []*ast.Node
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && ast.NodeText == n.Type && imgSizeSuffixDest.Match(n.Tokens) {
+			texts = append(texts, n)
+		}
+		return ast.WalkContinue
+	})
+
+	for _, text := range texts {
+		tokens, pos := text.Tokens, 0
+		for _, loc := range imgSizeSuffixDest.FindAllSubmatchIndex(tokens, -1) {
+			if pos < loc[0] {
+				text.InsertBefore(&ast.Node{Type: ast.NodeText, Tokens: tokens[pos:loc[0]]})
+			}
+			img := imgSizeSuffixDest.Expand(nil, []byte(`![$1]($2 "=$3")$4`), tokens, loc)
+			paragraph := parse.Inline("", img, options).Root.FirstChild
+			for n := paragraph.FirstChild; nil != n; {
+				next := n.Next
+				text.InsertBefore(n)
+				n = next
+			}
+			pos = loc[1]
+		}
+		if text.Tokens = tokens[pos:]; 0 == len(text.Tokens) {
+			text.Unlink()
+		}
+	}
+}
+
 func trimQuote(str string) string {
 	return strings.Trim(str, "\"'")
 }
+
+// splitList 将逗号分隔的 str 拆分为列表，忽略空项。
+func splitList(str string) (ret []string) {
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); "" != item {
+			ret = append(ret, item)
+		}
+	}
+	return
+}
+
+// loadCallouts 从 JSON 配置文件 confPath 加载提示块样式到 callouts 中，配置文件格式如下：
+//
+//	{"note": {"title": "注意", "icon": "info", "color": "#0969da", "background": "#ddf4ff"}}
+func loadCallouts(confPath string, callouts map[string]*PdfCallout) {
+	data, err := ioutil.ReadFile(confPath)
+	if nil != err {
+		logger.Fatal(err)
+	}
+
+	conf := map[string]struct {
+		Title      string
+		Icon       string
+		Color      string
+		Background string
+	}{}
+	if err = json.Unmarshal(data, &conf); nil != err {
+		logger.Fatalf("parse callouts conf [%s] failed: %s", confPath, err)
+	}
+	for typ, c := range conf {
+		callout := &PdfCallout{Title: c.Title, Icon: c.Icon, Color: parseColor(c.Color), Background: parseColor(c.Background)}
+		if nil == callout.Color {
+			callout.Color = &RGB{106, 115, 125}
+		}
+		callouts[strings.ToLower(typ)] = callout
+	}
+}
+
+// loadImageRewriteRules 从 JSON 配置文件 confPath 加载图片地址改写规则，preset 为 qiniu 时展开为七牛云图片处理预设，配置文件格式如下：
+//
+//	[{"hosts": ["cdn.example.com"], "template": "{urlNoQuery}?w={maxWidthPx}"}, {"preset": "qiniu"}]
+func loadImageRewriteRules(confPath string) (ret []*PdfImageRewriteRule) {
+	data, err := ioutil.ReadFile(confPath)
+	if nil != err {
+		logger.Fatal(err)
+	}
+
+	var conf []struct {
+		Preset   string
+		Hosts    []string
+		Pattern  string
+		Template string
+	}
+	if err = json.Unmarshal(data, &conf); nil != err {
+		logger.Fatalf("parse image rewrite conf [%s] failed: %s", confPath, err)
+	}
+	for _, c := range conf {
+		if "" != c.Preset {
+			if "qiniu" != strings.ToLower(c.Preset) {
+				logger.Fatalf("unknown image rewrite preset [%s]", c.Preset)
+			}
+			ret = append(ret, NewQiniuImageRewriteRules()...)
+			continue
+		}
+
+		rule := &PdfImageRewriteRule{Hosts: c.Hosts, Template: c.Template}
+		if "" != c.Pattern {
+			if rule.Pattern, err = regexp.Compile(c.Pattern); nil != err {
+				logger.Fatalf("invalid image rewrite pattern [%s]: %s", c.Pattern, err)
+			}
+		}
+		if "" == rule.Template {
+			logger.Fatalf("image rewrite rule [%s%s] has no template", strings.Join(c.Hosts, ","), c.Pattern)
+		}
+		ret = append(ret, rule)
+	}
+	return
+}
+
+// parseColor 解析形如 #RRGGBB 的颜色，为空时返回 nil。
+func parseColor(str string) *RGB {
+	str = strings.TrimPrefix(str, "#")
+	if "" == str {
+		return nil
+	}
+
+	c, err := strconv.ParseUint(str, 16, 32)
+	if nil != err || 6 != len(str) {
+		logger.Fatalf("invalid color [%s]", str)
+	}
+	return &RGB{uint8(c >> 16), uint8(c >> 8), uint8(c)}
+}
@@ -0,0 +1,99 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// boxDecoration 描述了带装饰样式的盒子在一页上的装饰区域。
+type boxDecoration struct {
+	page   int          // 所在页码，从 1 开始
+	x, y   float64      // 左上角坐标
+	width  float64      // 宽度，包括左边框和内边距
+	height float64      // 高度，盒子在该页上结束时才能确定
+	style  *PdfBoxStyle // 装饰样式
+}
+
+var pdfPageContents = regexp.MustCompile(`/Contents ([^\n]*)\n`)
+
+// appendBoxDecorations 通过 PDF 增量更新把盒子装饰 decorations 绘制到 gopdf 生成的文档 data 中各页内容之前。
+//
+// 盒子的高度要等到盒子结束或者换页时才能确定，而 gopdf 只能在页面内容之后追加绘制，所以这里为每页追加一个绘制装饰的内容流，
+// 并改写页面对象，让该内容流排在原内容流之前。外层盒子的装饰先于内层盒子记录，按照记录顺序绘制即可让内层盒子覆盖外层盒子。
+func appendBoxDecorations(data []byte, decorations []*boxDecoration, pageHeight float64) ([]byte, error) {
+	u, err := newPdfUpdate(data)
+	if nil != err {
+		return nil, err
+	}
+
+	var pages []int
+	streams := map[int]*strings.Builder{}
+	for _, decoration := range decorations {
+		ops := decoration.ops(pageHeight)
+		if "" == ops {
+			continue
+		}
+		stream := streams[decoration.page]
+		if nil == stream {
+			stream = &strings.Builder{}
+			streams[decoration.page] = stream
+			pages = append(pages, decoration.page)
+		}
+		stream.WriteString(ops)
+	}
+
+	for _, page := range pages {
+		pageID, err := u.page(page)
+		if nil != err {
+			return nil, err
+		}
+		dict, err := u.objectDict(pageID)
+		if nil != err {
+			return nil, err
+		}
+		contents := pdfPageContents.FindSubmatchIndex(dict)
+		if nil == contents {
+			return nil, fmt.Errorf("pdf page [%d] has no contents", page)
+		}
+
+		stream := "q\n" + streams[page].String() + "Q"
+		id := u.newID()
+		u.writeObject(id, fmt.Sprintf("<<\n  /Length %d\n>>\nstream\n%s\nendstream", len(stream), stream))
+		refs := fmt.Sprintf("/Contents [%d 0 R %s]\n", id, strings.TrimSpace(string(dict[contents[2]:contents[3]])))
+		u.writeObject(pageID, "<<"+string(dict[:contents[0]])+refs+string(dict[contents[1]:])+">>")
+	}
+	return u.bytes(), nil
+}
+
+// ops 返回绘制装饰背景和左边框的内容流操作，坐标转换为以页面左下角为原点。
+func (d *boxDecoration) ops(pageHeight float64) string {
+	if 0 >= d.height {
+		return ""
+	}
+
+	var ret strings.Builder
+	y := pageHeight - d.y - d.height
+	if background := d.style.Background; nil != background {
+		fmt.Fprintf(&ret, "%s %.2f %.2f %.2f %.2f re f\n", fillColorOp(background), d.x, y, d.width, d.height)
+	}
+	if border := d.style.BorderColor; nil != border && 0 < d.style.BorderWidth {
+		fmt.Fprintf(&ret, "%s %.2f %.2f %.2f %.2f re f\n", fillColorOp(border), d.x, y, d.style.BorderWidth, d.height)
+	}
+	return ret.String()
+}
+
+// fillColorOp 返回将填充色设置为 color 的内容流操作。
+func fillColorOp(color *RGB) string {
+	return fmt.Sprintf("%.3f %.3f %.3f rg", float64(color.R)/255, float64(color.G)/255, float64(color.B)/255)
+}
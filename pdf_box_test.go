@@ -0,0 +1,153 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/88250/lute/parse"
+	"github.com/88250/lute/render"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// newTestRenderer 使用 Go 字体创建渲染 markdown 的渲染器。
+func newTestRenderer(t *testing.T, markdown string) *PdfRenderer {
+	dir := t.TempDir()
+	for name, ttf := range map[string][]byte{"regular": goregular.TTF, "bold": gobold.TTF, "italic": goitalic.TTF} {
+		path := filepath.Join(dir, name+".ttf")
+		if err := os.WriteFile(path, ttf, 0644); nil != err {
+			t.Fatal(err)
+		}
+	}
+	tree := parse.Parse("", []byte(markdown), parse.NewOptions())
+	ret := NewPdfRenderer(tree, render.NewOptions(), filepath.Join(dir, "regular.ttf"), filepath.Join(dir, "bold.ttf"), filepath.Join(dir, "italic.ttf"))
+	ret.Cover = &PdfCover{}
+	return ret
+}
+
+var (
+	testXrefSection = regexp.MustCompile(`(?m)^(\d+) (\d+)\n`)
+	testXrefEntry   = regexp.MustCompile(`^(\d{10}) (\d{5}) ([nf]) \n`)
+)
+
+// lastXref 解析文档 data 最后一个交叉引用表，返回对象编号到位置的映射以及 trailer。
+func lastXref(t *testing.T, data []byte) (map[int]int, string) {
+	start := bytes.LastIndex(data, []byte("startxref\n"))
+	if 0 > start {
+		t.Fatal("startxref not found")
+	}
+	xrefOffset, err := strconv.Atoi(strings.Fields(string(data[start+len("startxref\n"):]))[0])
+	if nil != err || !bytes.HasPrefix(data[xrefOffset:], []byte("xref\n")) {
+		t.Fatalf("startxref [%d] does not point at xref", xrefOffset)
+	}
+	table := data[xrefOffset+len("xref\n") : start]
+	trailerStart := bytes.Index(table, []byte("trailer"))
+	if 0 > trailerStart {
+		t.Fatal("trailer not found")
+	}
+	trailer := string(table[trailerStart:])
+	table = table[:trailerStart]
+
+	ret := map[int]int{}
+	for 0 < len(table) {
+		section := testXrefSection.FindSubmatch(table)
+		if nil == section {
+			t.Fatalf("malformed xref section %q", table)
+		}
+		id, _ := strconv.Atoi(string(section[1]))
+		count, _ := strconv.Atoi(string(section[2]))
+		table = table[len(section[0]):]
+		for i := 0; i < count; i++ {
+			entry := testXrefEntry.FindSubmatch(table)
+			if nil == entry {
+				t.Fatalf("malformed xref entry %q", table)
+			}
+			if "n" == string(entry[3]) {
+				ret[id+i], _ = strconv.Atoi(string(entry[1]))
+			}
+			table = table[len(entry[0]):]
+		}
+	}
+	return ret, trailer
+}
+
+func TestBoxDecorations(t *testing.T) {
+	markdown := "> outer\n>\n> > inner\n\n" + strings.Repeat("> line\n>\n", 40)
+	r := newTestRenderer(t, markdown)
+	r.BlockquoteStyle.Background = &RGB{240, 240, 240}
+	r.pdf.AddPage()
+	r.Render()
+
+	// 外层引述、内层引述，以及跨页的引述在两页上的装饰
+	var pages []int
+	for _, decoration := range r.decorations {
+		if 0 >= decoration.height {
+			t.Errorf("decoration on page [%d] at %.2f has height %.2f", decoration.page, decoration.y, decoration.height)
+		}
+		pages = append(pages, decoration.page)
+	}
+	if got := fmt.Sprint(pages); "[1 1 1 2]" != got {
+		t.Fatalf("decorations on pages %s, want [1 1 1 2]", got)
+	}
+	outer, inner := r.decorations[0], r.decorations[1]
+	if inner.y < outer.y || inner.y+inner.height > outer.y+outer.height || inner.x <= outer.x {
+		t.Errorf("inner decoration %+v is not inside outer decoration %+v", inner, outer)
+	}
+	if first := r.decorations[2]; first.y+first.height > r.pageSize.H-r.margin {
+		t.Errorf("decoration %+v ends below the bottom margin", first)
+	}
+
+	data, err := r.pdf.GetBytesPdfReturnErr()
+	if nil != err {
+		t.Fatal(err)
+	}
+	r.pdf.Close()
+	updated, err := appendBoxDecorations(data, r.decorations, r.pageSize.H)
+	if nil != err {
+		t.Fatal(err)
+	}
+	offsets, _ := lastXref(t, updated)
+	object := func(id int) string {
+		obj := string(updated[offsets[id]:])
+		return obj[:strings.Index(obj, "endobj")]
+	}
+	contents := regexp.MustCompile(`/Contents \[(\d+) 0 R (\d+) 0 R\]`)
+	var updatedPages int
+	for id := range offsets {
+		page := object(id)
+		if !strings.Contains(page, "/Type /Page\n") {
+			continue
+		}
+		updatedPages++
+		refs := contents.FindStringSubmatch(page)
+		if nil == refs {
+			t.Fatalf("page [%d] does not draw decorations before its contents:\n%s", id, page)
+		}
+		decorationID, _ := strconv.Atoi(refs[1])
+		if _, ok := offsets[decorationID]; !ok {
+			t.Errorf("decoration stream [%d] of page [%d] is not appended", decorationID, id)
+		} else if stream := object(decorationID); !strings.Contains(stream, "stream\nq\n") || !strings.Contains(stream, " re f\nQ\nendstream") {
+			t.Errorf("unexpected decoration stream of page [%d]:\n%s", id, stream)
+		}
+	}
+	if 2 != updatedPages {
+		t.Errorf("updated %d pages, want 2", updatedPages)
+	}
+}
@@ -0,0 +1,150 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/88250/lute/ast"
+	"github.com/signintech/gopdf"
+)
+
+// PdfCallout 描述了提示块（GitHub 风格的 > [!NOTE] 引述）的样式。
+type PdfCallout struct {
+	Title      string // 标题，为空时使用类型名
+	Icon       string // 图标：info、check、bubble、warning、stop
+	Color      *RGB   // 主题色，用于左边框、图标和标题
+	Background *RGB   // 背景色，为空时不填充背景
+}
+
+// NewCallouts 创建 GitHub 支持的几种默认提示块样式，键为小写类型名。
+func NewCallouts() map[string]*PdfCallout {
+	return map[string]*PdfCallout{
+		"note":      {Title: "Note", Icon: "info", Color: &RGB{9, 105, 218}, Background: &RGB{221, 244, 255}},
+		"tip":       {Title: "Tip", Icon: "check", Color: &RGB{26, 127, 55}, Background: &RGB{218, 251, 225}},
+		"important": {Title: "Important", Icon: "bubble", Color: &RGB{130, 80, 223}, Background: &RGB{251, 239, 255}},
+		"warning":   {Title: "Warning", Icon: "warning", Color: &RGB{154, 103, 0}, Background: &RGB{255, 248, 197}},
+		"caution":   {Title: "Caution", Icon: "stop", Color: &RGB{207, 34, 46}, Background: &RGB{255, 235, 233}},
+	}
+}
+
+var calloutMarker = regexp.MustCompile(`^\[!([A-Za-z0-9_-]+)\][ \t]*(.*)$`)
+
+// callout 判断引述 blockquote 是否是提示块，如果是的话返回提示块样式和标题，[!TYPE] 标记所在的节点记录在 skipped 中，渲染时跳过，不修改语法树。
+func (r *PdfRenderer) callout(blockquote *ast.Node) (callout *PdfCallout, title string) {
+	paragraph := blockquote.FirstChild
+	if nil != paragraph && ast.NodeBlockquoteMarker == paragraph.Type {
+		paragraph = paragraph.Next
+	}
+	if nil == paragraph || ast.NodeParagraph != paragraph.Type {
+		return
+	}
+	text := paragraph.FirstChild
+	if nil == text || ast.NodeText != text.Type {
+		return
+	}
+
+	groups := calloutMarker.FindStringSubmatch(string(text.Tokens))
+	if nil == groups {
+		return
+	}
+	typ := strings.ToLower(groups[1])
+	callout = r.Callouts[typ]
+	if nil == callout {
+		return
+	}
+
+	title = strings.TrimSpace(groups[2])
+	if "" == title {
+		title = callout.Title
+	}
+	if "" == title {
+		title = strings.ToUpper(typ[:1]) + typ[1:]
+	}
+
+	r.skipped[text] = true
+	next := text.Next
+	if nil != next && (ast.NodeSoftBreak == next.Type || ast.NodeHardBreak == next.Type) {
+		r.skipped[next] = true
+		next = next.Next
+	}
+	if nil == next {
+		r.skipped[paragraph] = true
+	}
+	return
+}
+
+// renderCalloutTitle 在当前盒子内输出提示块标题行，包括图标和标题。
+func (r *PdfRenderer) renderCalloutTitle(callout *PdfCallout, title string) {
+	size := float64(r.fontSize)
+	x := r.peekBox().left
+	y := r.pdf.GetY() + 6
+	r.drawCalloutIcon(callout.Icon, x, y, size, callout.Color)
+
+	r.pdf.SetY(y)
+	r.pdf.SetX(x + size + 6)
+	r.pushFont(&Font{"bold", "B", r.fontSize})
+	r.pushTextColor(callout.Color)
+	r.WriteString(title)
+	r.popTextColor()
+	r.popFont()
+	r.Newline()
+}
+
+// drawCalloutIcon 在 (x, y) 处绘制边长为 size 的矢量图标。
+func (r *PdfRenderer) drawCalloutIcon(icon string, x, y, size float64, color *RGB) {
+	r.pdf.SetFillColor(color.R, color.G, color.B)
+	cx := x + size/2
+	switch icon {
+	case "bubble":
+		r.pdf.Rectangle(x, y, x+size, y+size, "F", size/5, 4)
+	case "warning":
+		r.pdf.Polygon([]gopdf.Point{{X: cx, Y: y}, {X: x + size, Y: y + size}, {X: x, Y: y + size}}, "F")
+	case "stop":
+		r.pdf.Polygon(polygonPoints(cx, y+size/2, size/2, 8, math.Pi/8), "F")
+	default:
+		r.pdf.Polygon(polygonPoints(cx, y+size/2, size/2, 32, 0), "F")
+	}
+
+	// 图标内的白色符号
+	r.pdf.SetFillColor(255, 255, 255)
+	bar := size / 8
+	switch icon {
+	case "info":
+		r.pdf.RectFromUpperLeftWithStyle(cx-bar/2, y+size*0.2, bar, bar, "F")
+		r.pdf.RectFromUpperLeftWithStyle(cx-bar/2, y+size*0.4, bar, size*0.4, "F")
+	case "check":
+		r.pdf.SetStrokeColor(255, 255, 255)
+		r.pdf.SetLineWidth(bar)
+		r.pdf.Line(x+size*0.28, y+size*0.52, x+size*0.44, y+size*0.68)
+		r.pdf.Line(x+size*0.44, y+size*0.68, x+size*0.72, y+size*0.34)
+		r.pdf.SetLineWidth(1)
+		r.pdf.SetStrokeColor(0, 0, 0)
+	default:
+		top := y + size*0.2
+		if "warning" == icon {
+			top = y + size*0.35
+		}
+		r.pdf.RectFromUpperLeftWithStyle(cx-bar/2, top, bar, y+size*0.62-top, "F")
+		r.pdf.RectFromUpperLeftWithStyle(cx-bar/2, y+size*0.7, bar, bar, "F")
+	}
+}
+
+// polygonPoints 返回以 (cx, cy) 为中心、外接圆半径为 radius 的正 n 边形顶点，n 较大时可近似为圆。
+func polygonPoints(cx, cy, radius float64, n int, rotation float64) (ret []gopdf.Point) {
+	for i := 0; i < n; i++ {
+		angle := rotation + 2*math.Pi*float64(i)/float64(n)
+		ret = append(ret, gopdf.Point{X: cx + radius*math.Cos(angle), Y: cy + radius*math.Sin(angle)})
+	}
+	return
+}
@@ -0,0 +1,73 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+)
+
+func TestCallout(t *testing.T) {
+	tests := []struct {
+		md        string
+		typ       string // 期望的提示块类型，为空时期望不是提示块
+		title     string
+		paragraph bool // 标记所在的段落是否还有其他内容
+	}{
+		{"> [!NOTE]\n> body", "note", "Note", true},
+		{"> [!warning] Be careful\n> body", "warning", "Be careful", true},
+		{"> [!TIP]\n>\n> body", "tip", "Tip", false},
+		{"> [!TIP]", "tip", "Tip", false},
+		{"> [!UNKNOWN]\n> body", "", "", false},
+		{"> body [!NOTE]", "", "", false},
+		{"> **[!NOTE]**", "", "", false},
+	}
+	for _, test := range tests {
+		tree := parse.Parse("", []byte(test.md), parse.NewOptions())
+		before := dumpTree(tree.Root)
+		r := &PdfRenderer{Callouts: NewCallouts(), skipped: map[*ast.Node]bool{}}
+		blockquote := tree.Root.FirstChild
+		callout, title := r.callout(blockquote)
+		if after := dumpTree(tree.Root); before != after {
+			t.Errorf("callout(%q) modified tree:\n%s\nwant:\n%s", test.md, after, before)
+		}
+
+		if "" == test.typ {
+			if nil != callout || 0 < len(r.skipped) {
+				t.Errorf("callout(%q) = %v, skipped %d nodes, want no callout", test.md, callout, len(r.skipped))
+			}
+			continue
+		}
+		if r.Callouts[test.typ] != callout || test.title != title {
+			t.Errorf("callout(%q) = %v, %q, want %s, %q", test.md, callout, title, test.typ, test.title)
+		}
+		paragraph := blockquote.FirstChild.Next
+		if !r.skipped[paragraph.FirstChild] {
+			t.Errorf("callout(%q) did not skip marker", test.md)
+		}
+		if test.paragraph == r.skipped[paragraph] {
+			t.Errorf("callout(%q) skipped paragraph = %v, want %v", test.md, r.skipped[paragraph], !test.paragraph)
+		}
+	}
+}
+
+// dumpTree 将语法树输出为便于比较的文本。
+func dumpTree(root *ast.Node) (ret string) {
+	ast.Walk(root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering {
+			ret += n.Type.String() + "[" + string(n.Tokens) + "]\n"
+		}
+		return ast.WalkContinue
+	})
+	return
+}
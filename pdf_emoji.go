@@ -0,0 +1,115 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"bytes"
+	"html"
+	"image"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/signintech/gopdf"
+)
+
+// emojiFileName 按照 Twemoji 的规则返回 Emoji emoji 对应的图片文件名（不含扩展名），比如 😀 对应 1f600。
+//
+// 不包含零宽连接符（U+200D）的 Emoji 需要去掉变体选择符（U+FE0F）。
+func emojiFileName(emoji string) string {
+	if !strings.ContainsRune(emoji, '\u200d') {
+		emoji = strings.ReplaceAll(emoji, "\ufe0f", "")
+	}
+	var codes []string
+	for _, c := range emoji {
+		codes = append(codes, strconv.FormatInt(int64(c), 16))
+	}
+	return strings.Join(codes, "-")
+}
+
+// emojiImg 返回 Emoji emoji 的图片数据，先查找 EmojiDir 目录，没有的话从 EmojiCDN 下载，都没有时返回 nil。
+func (r *PdfRenderer) emojiImg(emoji string) []byte {
+	name := emojiFileName(emoji)
+	if data, ok := r.emojis[name]; ok {
+		return data
+	}
+
+	var data []byte
+	if "" != r.EmojiDir {
+		data, _ = ioutil.ReadFile(filepath.Join(r.EmojiDir, name+".png"))
+	}
+	if nil == data && "" != r.EmojiCDN {
+		// Emoji 没有图片时输出别名，不作为加载失败的图片记录
+		data, _ = r.ImageResolver.ResolveImage(strings.TrimSuffix(r.EmojiCDN, "/") + "/" + name + ".png")
+	}
+	if nil == data {
+		logger.Warnf("emoji image [%s] not found", name)
+	}
+	r.emojis[name] = data
+	return data
+}
+
+var emojiImgSrc = regexp.MustCompile(`src="([^"]+)"`)
+
+// emojiSiteImg 下载图片 Emoji 节点 tokens（<img src="..." /> 形式）引用的图片，失败时返回 nil。
+func (r *PdfRenderer) emojiSiteImg(tokens string) []byte {
+	groups := emojiImgSrc.FindStringSubmatch(tokens)
+	if nil == groups {
+		return nil
+	}
+	src := html.UnescapeString(groups[1])
+	if data, ok := r.emojis[src]; ok {
+		return data
+	}
+
+	data, err := r.ImageResolver.ResolveImage(src)
+	if nil != err {
+		logger.Warnf("emoji image [%s] not found: %s", src, err)
+		data = nil
+	}
+	r.emojis[src] = data
+	return data
+}
+
+// renderInlineImg 在当前位置按照文本高度输出行内图片 data，保持宽高比，图片底部略低于文本基线，放不下时先换行。
+func (r *PdfRenderer) renderInlineImg(data []byte) bool {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if nil != err || 1 > config.Height {
+		logger.Warnf("decode inline image failed: %v", err)
+		return false
+	}
+	if !embeddableImg(data, format) {
+		if data, err = reencodeImg(data); nil != err {
+			logger.Warnf("reencode inline %s image failed: %s", format, err)
+			return false
+		}
+	}
+	holder, err := gopdf.ImageHolderByBytes(data)
+	if nil != err {
+		logger.Warnf("load inline image failed: %s", err)
+		return false
+	}
+
+	size := float64(r.peekFont().size)
+	width := size * float64(config.Width) / float64(config.Height)
+	x, baseline := r.inlineImgPos(width)
+	y := r.pdf.GetY()
+	if err = r.pdf.ImageByHolder(holder, x, baseline+0.12*size-size, &gopdf.Rect{W: width, H: size}); nil != err {
+		logger.Warnf("draw inline image failed: %s", err)
+		return false
+	}
+	r.pdf.SetY(y)
+	r.pdf.SetX(x + width)
+	r.LastOut = ':'
+	return true
+}
@@ -0,0 +1,107 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/util"
+)
+
+// numberEquations 在渲染前遍历文档中的公式块，为其分配编号并记录标签对应的编号，这样引用可以出现在公式之前。
+//
+// 带 \tag{} 的公式使用指定编号，带 \label{} 的公式自动编号，开启 MathAutoNumber 时所有公式块都自动编号，
+// \nonumber、\notag 和带 * 的环境不编号。
+func (r *PdfRenderer) numberEquations(root *ast.Node) {
+	r.equations = map[*ast.Node]*mathEquation{}
+	r.equationLabels = map[string]*mathEquation{}
+	num := 0
+	ast.Walk(root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || ast.NodeMathBlockContent != n.Type {
+			return ast.WalkContinue
+		}
+
+		_, eq := parseMath(util.BytesToStr(n.Tokens))
+		if "" == eq.number && !eq.notag && (r.MathAutoNumber || "" != eq.label) {
+			num++
+			eq.number = strconv.Itoa(num)
+		}
+		if "" != eq.label {
+			if _, ok := r.equationLabels[eq.label]; ok {
+				logger.Warnf("duplicated equation label [%s]", eq.label)
+			} else {
+				r.equationLabels[eq.label] = eq
+			}
+		}
+		r.equations[n] = eq
+		return ast.WalkContinue
+	})
+}
+
+// equationRefText 返回引用标签 label 的公式时显示的文本，eqref 为 true 时编号带括号，没有该标签时 ok 为 false。
+func (r *PdfRenderer) equationRefText(label string, eqref bool) (text string, ok bool) {
+	eq := r.equationLabels[label]
+	if nil == eq || "" == eq.number {
+		return "", false
+	}
+	if eqref {
+		return eq.tag(), true
+	}
+	return eq.number, true
+}
+
+// equationAnchor 返回标签 label 对应的公式锚点名。
+func equationAnchor(label string) string {
+	return "eq-" + label
+}
+
+// layoutMathRef 排版公式中的 \eqref{}、\ref{} 引用。
+func (r *PdfRenderer) layoutMathRef(n *mathNode, size float64) *mathBox {
+	text, anchor := r.refText(n.ref, "eqref" == n.text)
+	ret := r.mathText(text, "regular", size)
+	if "" != anchor {
+		ret.color = &RGB{66, 133, 244}
+		ret.link = anchor
+	}
+	return ret
+}
+
+// renderEquationTag 在公式块右侧输出编号 eq，并在公式顶部 top 处设置锚点。
+func (r *PdfRenderer) renderEquationTag(eq *mathEquation, box *mathBox, top, baseline float64) {
+	if "" != eq.label {
+		y := r.pdf.GetY()
+		r.pdf.SetY(top)
+		r.pdf.SetAnchor(equationAnchor(eq.label))
+		r.pdf.SetY(y)
+	}
+
+	tag := eq.tag()
+	if "" == tag {
+		return
+	}
+	// 多行公式的编号垂直居中
+	size := float64(r.fontSize)
+	if box.height+box.depth > 2*r.lineHeight {
+		baseline = top + (box.height+box.depth)/2 + mathAxis(size)
+	}
+	tagBox := r.mathText(tag, "regular", size)
+	r.drawMathBox(tagBox, r.peekBox().right-tagBox.width, baseline, r.peekTextColor())
+}
+
+// equationTagWidth 返回公式块 eq 的编号宽度，没有编号时返回 0。
+func (r *PdfRenderer) equationTagWidth(eq *mathEquation) float64 {
+	if nil == eq || "" == eq.tag() {
+		return 0
+	}
+	return math.Ceil(r.mathText(eq.tag(), "regular", float64(r.fontSize)).width)
+}
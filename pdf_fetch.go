@@ -0,0 +1,409 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/util"
+)
+
+// imgDownload 描述了一个网络图片的下载任务。
+type imgDownload struct {
+	path string        // 下载后的本地文件路径
+	err  error         // 下载失败的原因
+	done chan struct{} // 下载结束后关闭
+}
+
+// imgCacheMeta 描述了缓存图片的元数据，用于重新验证缓存是否过期。
+type imgCacheMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// defaultImgCacheDir 返回默认的图片缓存目录，位于用户缓存目录下。
+func defaultImgCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if nil != err {
+		return ""
+	}
+	return filepath.Join(dir, "lute-pdf", "images")
+}
+
+// PrefetchImages 收集文档和封面中引用的网络图片，在后台使用 ImageWorkers 个协程按照出现顺序并发下载，渲染到图片时只需等待对应的下载完成。
+//
+// ImageResolver 没有用到默认的加载方式时不做预下载。
+func (r *PdfRenderer) PrefetchImages() {
+	if !r.usesDefaultResolver() {
+		return
+	}
+
+	links := r.collectImgLinks()
+	if 0 == len(links) {
+		return
+	}
+
+	queue := make(chan string, len(links))
+	for _, link := range links {
+		queue <- link
+	}
+	close(queue)
+	workers := r.ImageWorkers
+	if 1 > workers {
+		workers = 1
+	}
+	for i := 0; i < workers && i < len(links); i++ {
+		r.fetching.Add(1)
+		go func() {
+			defer r.fetching.Done()
+			for link := range queue {
+				r.fetchImg(link)
+			}
+		}()
+	}
+}
+
+// collectImgLinks 按照出现顺序返回封面图标、图片和 Emoji 引用的网络图片地址，地址已经去重。
+func (r *PdfRenderer) collectImgLinks() (ret []string) {
+	seen := map[string]bool{}
+	add := func(src string) {
+		if link, ok := r.remoteImgLink(src); ok && !seen[link] {
+			seen[link] = true
+			ret = append(ret, link)
+		}
+	}
+
+	if nil != r.Cover {
+		add(r.Cover.LogoLink)
+	}
+	ast.Walk(r.Tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+		switch n.Type {
+		case ast.NodeImage:
+			if dest := n.ChildByType(ast.NodeLinkDest); nil != dest {
+				add(util.BytesToStr(dest.Tokens))
+			}
+		case ast.NodeEmojiImg:
+			if groups := emojiImgSrc.FindStringSubmatch(util.BytesToStr(n.Tokens)); nil != groups {
+				add(html.UnescapeString(groups[1]))
+			}
+		case ast.NodeEmojiUnicode:
+			name := emojiFileName(util.BytesToStr(n.Tokens))
+			if "" != r.EmojiCDN && ("" == r.EmojiDir || !gulu.File.IsExist(filepath.Join(r.EmojiDir, name+".png"))) {
+				add(strings.TrimSuffix(r.EmojiCDN, "/") + "/" + name + ".png")
+			}
+		}
+		return ast.WalkContinue
+	})
+	return
+}
+
+// remoteImgLink 返回图片地址 src 实际的下载地址，src 不是 HTTP(S) 地址时 ok 为 false。
+func (r *PdfRenderer) remoteImgLink(src string) (link string, ok bool) {
+	if strings.HasPrefix(src, "//") {
+		src = "https:" + src
+	}
+	u, err := url.Parse(src)
+	if nil != err || !strings.HasPrefix(u.Scheme, "http") {
+		return src, false
+	}
+	return r.rewriteImgLink(src), true
+}
+
+// dataImgPrefix 是 data: URI 图片数据的键前缀，键的其余部分为 URI 的 SHA-1。
+const dataImgPrefix = "data-uri:"
+
+// imgKey 返回图片 src 加载后的数据的键，data: URI 可能很长，使用摘要作为键。
+func imgKey(src string) string {
+	if !strings.HasPrefix(strings.ToLower(src), "data:") {
+		return src
+	}
+	hash := sha1.Sum([]byte(src))
+	return dataImgPrefix + hex.EncodeToString(hash[:])
+}
+
+// decodeDataURI 解码 data:[<媒体类型>][;base64],<数据> 形式的 URI，数据可以是 Base64 编码或者百分号编码。
+func decodeDataURI(uri string) ([]byte, error) {
+	comma := strings.IndexByte(uri, ',')
+	if !strings.HasPrefix(strings.ToLower(uri), "data:") || 0 > comma {
+		return nil, fmt.Errorf("invalid data URI")
+	}
+	mediaType, payload := strings.ToLower(uri[len("data:"):comma]), uri[comma+1:]
+	if strings.Contains(payload, "%") {
+		unescaped, err := url.PathUnescape(payload)
+		if nil != err {
+			return nil, fmt.Errorf("invalid data URI: %w", err)
+		}
+		payload = unescaped
+	}
+	if !strings.HasSuffix(mediaType, ";base64") {
+		return []byte(payload), nil
+	}
+
+	payload = strings.Join(strings.Fields(payload), "")
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err := encoding.DecodeString(payload); nil == err {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid base64 data in data URI")
+}
+
+// shortImgSrc 返回用于日志和占位框的图片地址，data: URI 只保留开头部分。
+func shortImgSrc(src string) string {
+	if strings.HasPrefix(strings.ToLower(src), "data:") && 32 < len(src) {
+		return src[:32] + "..."
+	}
+	return src
+}
+
+// errImgSandbox 表示本地图片路径超出了 ImageRoot 目录。
+var errImgSandbox = errors.New("blocked by image sandbox")
+
+// localImgPath 将本地图片地址 src 解析为文件路径，相对路径基于 ImageRoot，文件不存在或者不是普通文件（比如目录）时返回错误。
+//
+// src 可以是 file:// 地址，也可以包含 %20 等转义字符。开启 ImageSandbox 时只允许访问 ImageRoot 目录内的文件，封面图标由调用方配置，不受限制。
+func (r *PdfRenderer) localImgPath(src string) (string, error) {
+	sandboxed := r.ImageSandbox && (nil == r.Cover || "" == r.Cover.LogoLink || src != r.Cover.LogoLink)
+	if strings.HasPrefix(src, "file://") {
+		if sandboxed {
+			return "", fmt.Errorf("%w: file URL [%s] is not allowed", errImgSandbox, src)
+		}
+		if u, err := url.Parse(src); nil == err {
+			src = u.Path
+		}
+	}
+
+	candidates := []string{src}
+	if unescaped, err := url.PathUnescape(src); nil == err && unescaped != src {
+		candidates = append(candidates, unescaped)
+	}
+	var ret string
+	for _, candidate := range candidates {
+		ret = filepath.FromSlash(candidate)
+		if sandboxed {
+			if err := checkSandboxPath(ret); nil != err {
+				return "", err
+			}
+		}
+		if !filepath.IsAbs(ret) {
+			ret = filepath.Join(r.ImageRoot, ret)
+		}
+		info, err := os.Stat(ret)
+		if nil != err {
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			return "", fmt.Errorf("path [%s] is not a regular file", ret)
+		}
+		if sandboxed {
+			if err := r.checkSandboxLink(ret); nil != err {
+				return "", err
+			}
+		}
+		return ret, nil
+	}
+	return "", fmt.Errorf("file [%s] not found", ret)
+}
+
+// checkSandboxPath 检查相对于 ImageRoot 的路径 imgPath，不允许绝对路径和通过 .. 跳出 ImageRoot。
+func checkSandboxPath(imgPath string) error {
+	if filepath.IsAbs(imgPath) || "" != filepath.VolumeName(imgPath) || strings.HasPrefix(imgPath, "/") || strings.HasPrefix(imgPath, `\`) {
+		return fmt.Errorf("%w: absolute path [%s] is not allowed", errImgSandbox, imgPath)
+	}
+	if cleaned := filepath.Clean(imgPath); ".." == cleaned || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%w: path [%s] escapes the image root", errImgSandbox, imgPath)
+	}
+	return nil
+}
+
+// checkSandboxLink 解析文件 imgPath 的符号链接，检查实际的文件仍然位于 ImageRoot 目录内。
+func (r *PdfRenderer) checkSandboxLink(imgPath string) error {
+	root, err := filepath.Abs(r.ImageRoot)
+	if nil == err {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if nil != err {
+		return fmt.Errorf("%w: resolve image root [%s] failed: %s", errImgSandbox, r.ImageRoot, err)
+	}
+	target, err := filepath.EvalSymlinks(imgPath)
+	if nil == err {
+		target, err = filepath.Abs(target)
+	}
+	if nil != err {
+		return fmt.Errorf("%w: resolve path [%s] failed: %s", errImgSandbox, imgPath, err)
+	}
+	if rel, err := filepath.Rel(root, target); nil != err || ".." == rel || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%w: path [%s] links to [%s] outside the image root", errImgSandbox, imgPath, target)
+	}
+	return nil
+}
+
+// fetchImg 下载网络图片 link，同一个地址只会下载一次，其他协程正在下载时等待其完成。
+func (r *PdfRenderer) fetchImg(link string) *imgDownload {
+	r.downloadsLock.Lock()
+	ret := r.downloads[link]
+	started := nil != ret
+	if !started {
+		ret = &imgDownload{done: make(chan struct{})}
+		r.downloads[link] = ret
+	}
+	r.downloadsLock.Unlock()
+
+	if started {
+		<-ret.done
+		return ret
+	}
+	ret.path, ret.err = r.downloadCachedImg(link)
+	close(ret.done)
+	return ret
+}
+
+// imgCacheDir 返回图片缓存目录，ImageCacheDir 为空时使用临时目录，保存 PDF 后删除。
+func (r *PdfRenderer) imgCacheDir() (string, error) {
+	r.downloadsLock.Lock()
+	defer r.downloadsLock.Unlock()
+
+	if "" != r.ImageCacheDir {
+		return r.ImageCacheDir, os.MkdirAll(r.ImageCacheDir, 0755)
+	}
+	if "" == r.tempImgCacheDir {
+		dir, err := ioutil.TempDir("", "lute-pdf.img.")
+		if nil != err {
+			return "", err
+		}
+		r.tempImgCacheDir = dir
+	}
+	return r.tempImgCacheDir, nil
+}
+
+// downloadCachedImg 将网络图片 link 下载到缓存目录并返回本地文件路径，缓存文件以地址的 SHA-1 命名。
+//
+// 已经缓存的图片通过 ETag 和 Last-Modified 重新验证，服务端返回 304 或者请求失败时直接使用缓存，离线模式下只使用缓存。
+// 请求遵循 NetPolicy，不允许访问的地址即使已经缓存也不会使用。
+func (r *PdfRenderer) downloadCachedImg(link string) (string, error) {
+	dir, err := r.imgCacheDir()
+	if nil != err {
+		return "", fmt.Errorf("create image cache dir failed: %w", err)
+	}
+	hash := sha1.Sum([]byte(link))
+	dataPath := filepath.Join(dir, hex.EncodeToString(hash[:]))
+	metaPath := dataPath + ".json"
+
+	meta := &imgCacheMeta{}
+	cached := false
+	if data, err := ioutil.ReadFile(metaPath); nil == err && nil == json.Unmarshal(data, meta) && link == meta.URL {
+		cached = gulu.File.IsExist(dataPath)
+	}
+
+	u, err := url.Parse(link)
+	if nil != err {
+		return "", err
+	}
+	if r.NetPolicy.Offline {
+		// 离线时不会发起请求，只需要检查主机名，在线时由 do 检查
+		if err = r.NetPolicy.checkHost(u); nil != err {
+			return "", err
+		}
+		if cached {
+			return dataPath, nil
+		}
+		return "", errImgOffline
+	}
+
+	req := &http.Request{
+		Header: http.Header{
+			"User-Agent": []string{"Lute-PDF; +https://github.com/88250/lute-pdf"},
+		},
+		URL: u,
+	}
+	if cached {
+		if "" != meta.ETag {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if "" != meta.LastModified {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+	resp, err := r.NetPolicy.do(req)
+	if nil != err {
+		if cached && !errors.Is(err, errImgBlocked) {
+			logger.Warnf("revalidate image [%s] failed, use the cached one: %s", link, err)
+			return dataPath, nil
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+	if http.StatusNotModified == resp.StatusCode && cached {
+		return dataPath, nil
+	}
+	if 200 != resp.StatusCode {
+		if cached {
+			logger.Warnf("revalidate image [%s] failed, use the cached one: status code is [%d]", link, resp.StatusCode)
+			return dataPath, nil
+		}
+		return "", fmt.Errorf("status code is [%d]", resp.StatusCode)
+	}
+
+	data, err := r.NetPolicy.readBody(resp)
+	if nil != err {
+		return "", err
+	}
+	// 先写入临时文件再重命名，避免中断时留下不完整的缓存
+	file, err := ioutil.TempFile(dir, filepath.Base(dataPath)+".")
+	if nil != err {
+		return "", err
+	}
+	_, err = file.Write(data)
+	file.Close()
+	if nil == err {
+		err = os.Rename(file.Name(), dataPath)
+	}
+	if nil != err {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	meta = &imgCacheMeta{URL: link, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if data, err = json.Marshal(meta); nil == err {
+		err = ioutil.WriteFile(metaPath, data, 0644)
+	}
+	if nil != err {
+		logger.Warnf("write image cache meta [%s] failed: %s", link, err)
+	}
+	return dataPath, nil
+}
+
+// cleanImgCache 等待后台下载结束，删除临时的图片缓存目录。
+func (r *PdfRenderer) cleanImgCache() {
+	r.fetching.Wait()
+	if "" != r.tempImgCacheDir {
+		os.RemoveAll(r.tempImgCacheDir)
+		r.tempImgCacheDir = ""
+	}
+}
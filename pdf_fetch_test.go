@@ -0,0 +1,192 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newSandboxDir 创建测试用的目录结构：
+//
+//	root/img/a.png
+//	root/my pic.png
+//	root/dir/
+//	root/link-in.png -> root/img/a.png
+//	root/link-out.png -> outside/secret.png
+//	root/link-dir -> outside
+//	outside/secret.png
+func newSandboxDir(t *testing.T) (root, outside string) {
+	base := t.TempDir()
+	root = filepath.Join(base, "root")
+	outside = filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "img"), filepath.Join(root, "dir"), outside} {
+		if err := os.MkdirAll(dir, 0755); nil != err {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join(root, "img", "a.png"), filepath.Join(root, "my pic.png"), filepath.Join(outside, "secret.png")} {
+		if err := ioutil.WriteFile(file, []byte("png"), 0644); nil != err {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		filepath.Join(root, "link-in.png"):  filepath.Join(root, "img", "a.png"),
+		filepath.Join(root, "link-out.png"): filepath.Join(outside, "secret.png"),
+		filepath.Join(root, "link-dir"):     outside,
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); nil != err {
+			t.Skipf("symlink is not supported: %s", err)
+		}
+	}
+	return
+}
+
+func TestLocalImgPath(t *testing.T) {
+	root, outside := newSandboxDir(t)
+	secret := filepath.Join(outside, "secret.png")
+
+	tests := []struct {
+		src     string
+		sandbox bool
+		want    string // 期望的路径，为空时期望返回错误
+		sandErr bool   // 是否期望沙箱错误
+	}{
+		{"img/a.png", true, filepath.Join(root, "img", "a.png"), false},
+		{"./img/../img/a.png", true, filepath.Join(root, "img", "a.png"), false},
+		{"my%20pic.png", true, filepath.Join(root, "my pic.png"), false},
+		{"link-in.png", true, filepath.Join(root, "link-in.png"), false},
+		{"../outside/secret.png", true, "", true},
+		{"img/../../outside/secret.png", true, "", true},
+		{"..%2Foutside%2Fsecret.png", true, "", true},
+		{secret, true, "", true},
+		{"file://" + filepath.ToSlash(secret), true, "", true},
+		{"link-out.png", true, "", true},
+		{"link-dir/secret.png", true, "", true},
+		{"dir", true, "", false},
+		{"missing.png", true, "", false},
+		{"", true, "", false},
+		{"../outside/secret.png", false, filepath.Join(root, "..", "outside", "secret.png"), false},
+		{secret, false, secret, false},
+		{"file://" + filepath.ToSlash(secret), false, secret, false},
+		{"link-out.png", false, filepath.Join(root, "link-out.png"), false},
+	}
+	for _, test := range tests {
+		r := &PdfRenderer{ImageRoot: root, ImageSandbox: test.sandbox}
+		got, err := r.localImgPath(test.src)
+		if "" != test.want {
+			if nil != err || filepath.Clean(test.want) != filepath.Clean(got) {
+				t.Errorf("localImgPath(%q, sandbox=%v) = %q, %v, want %q", test.src, test.sandbox, got, err, test.want)
+			}
+			continue
+		}
+		if nil == err {
+			t.Errorf("localImgPath(%q, sandbox=%v) = %q, want error", test.src, test.sandbox, got)
+			continue
+		}
+		if test.sandErr != errors.Is(err, errImgSandbox) {
+			t.Errorf("localImgPath(%q, sandbox=%v) error = %v, want sandbox error %v", test.src, test.sandbox, err, test.sandErr)
+		}
+	}
+}
+
+func TestLocalImgPathCoverLogo(t *testing.T) {
+	_, outside := newSandboxDir(t)
+	secret := filepath.Join(outside, "secret.png")
+
+	// 封面图标由调用方配置，不受沙箱限制
+	r := &PdfRenderer{ImageRoot: t.TempDir(), ImageSandbox: true, Cover: &PdfCover{LogoLink: secret}}
+	if got, err := r.localImgPath(secret); nil != err || secret != got {
+		t.Errorf("localImgPath(cover logo) = %q, %v, want %q", got, err, secret)
+	}
+}
+
+func TestCheckSandboxPath(t *testing.T) {
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{"a.png", true},
+		{"img/a.png", true},
+		{"img/../a.png", true},
+		{"..a.png", true},
+		{"a..png", true},
+		{"..", false},
+		{"../a.png", false},
+		{"img/../../a.png", false},
+		{"/etc/passwd", false},
+		{`\windows\a.png`, false},
+	}
+	for _, test := range tests {
+		err := checkSandboxPath(filepath.FromSlash(test.path))
+		if test.ok != (nil == err) {
+			t.Errorf("checkSandboxPath(%q) = %v, want ok %v", test.path, err, test.ok)
+		}
+		if nil != err && !errors.Is(err, errImgSandbox) {
+			t.Errorf("checkSandboxPath(%q) = %v, want sandbox error", test.path, err)
+		}
+	}
+}
+
+func TestDecodeDataURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string // 期望的数据，为空时期望返回错误
+	}{
+		// base64
+		{"data:image/png;base64,aGVsbG8=", "hello"},
+		{"DATA:image/png;BASE64,aGVsbG8=", "hello"},
+		{"data:image/png;base64,aGVsbG8", "hello"},
+		{"data:image/png;base64,aGV s\nbG8=", "hello"},
+		{"data:image/png;name=a.png;base64,aGVsbG8=", "hello"},
+		{"data:image/png;base64,-_8=", "\xfb\xff"},
+		{"data:image/png;base64,+/8=", "\xfb\xff"},
+		{"data:image/png;base64,%2B%2F8%3D", "\xfb\xff"},
+		{"data:image/png;base64,aGVsbG8=,", ""},
+		{"data:image/png;base64,aGVsbG8*", ""},
+		{"data:image/png;base64,a", ""},
+		{"data:image/png;base64,%zz", ""},
+
+		// 非 base64
+		{"data:image/svg+xml,<svg></svg>", "<svg></svg>"},
+		{"data:image/svg+xml,%3Csvg%20a=%221%22%3E%3C/svg%3E", `<svg a="1"></svg>`},
+		{"data:image/svg+xml;utf8,<svg>+</svg>", "<svg>+</svg>"},
+		{"data:image/svg+xml,100%", ""},
+		{"data:image/svg+xml,%E4%B8%AD", "中"},
+
+		// 缺少 mediatype
+		{"data:,hello", "hello"},
+		{"data:;base64,aGVsbG8=", "hello"},
+		{"data:base64,aGVsbG8=", "aGVsbG8="},
+
+		// 缺少逗号或者不是 data: URI
+		{"data:image/png;base64", ""},
+		{"data:", ""},
+		{"image/png;base64,aGVsbG8=", ""},
+	}
+	for _, test := range tests {
+		got, err := decodeDataURI(test.uri)
+		if "" == test.want {
+			if nil == err {
+				t.Errorf("decodeDataURI(%q) = %q, want error", test.uri, got)
+			}
+			continue
+		}
+		if nil != err || !bytes.Equal([]byte(test.want), got) {
+			t.Errorf("decodeDataURI(%q) = %q, %v, want %q", test.uri, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/lex"
+	"github.com/88250/lute/util"
+)
+
+// figure 描述了一张带题注的图片。
+type figure struct {
+	number  int    // 编号
+	label   string // 标签，用于引用，可以为空
+	caption string // 题注文本，取自替代文本或者标题
+}
+
+// text 返回图片的完整题注，比如 Figure 1: 架构。
+func (fig *figure) text(figureLabel string) string {
+	ret := figureLabel + " " + strconv.Itoa(fig.number)
+	if "" != fig.caption {
+		ret += ": " + fig.caption
+	}
+	return ret
+}
+
+// figureLabelMark 匹配紧跟在图片后的标签标记，比如 ![](a.png){#fig:arch}。
+var figureLabelMark = regexp.MustCompile(`^\s*\{#((?:fig:)?[^{}\s]+)\}`)
+
+// numberFigures 在渲染前遍历文档中独占一个段落的图片，为其分配编号并记录标签，这样引用可以出现在图片之前。
+//
+// 标签取自行级属性 id 或者紧跟在图片后的 {#fig:label}，题注取自替代文本，没有的话使用标题。
+func (r *PdfRenderer) numberFigures(root *ast.Node) {
+	r.figures = map[*ast.Node]*figure{}
+	r.figureLabels = map[string]*figure{}
+	r.figureList = nil
+	if !r.ImageCaption {
+		return
+	}
+
+	ast.Walk(root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || ast.NodeImage != n.Type || !standaloneImage(n) {
+			return ast.WalkContinue
+		}
+
+		fig := &figure{number: len(r.figureList) + 1}
+		for _, kv := range n.KramdownIAL {
+			if "id" == kv[0] {
+				fig.label = kv[1]
+			}
+		}
+		for next := n.Next; nil != next; next = next.Next {
+			if ast.NodeText != next.Type {
+				continue
+			}
+			if loc := figureLabelMark.FindSubmatchIndex(next.Tokens); nil != loc {
+				fig.label = string(next.Tokens[loc[2]:loc[3]])
+				r.textOffsets[next] = loc[1] // 绘制文本时跳过标签标记，不修改语法树
+			}
+			break
+		}
+		fig.caption = strings.TrimSpace(n.Text())
+		if "" == fig.caption {
+			_, fig.caption = parseImgAttrs(n)
+		}
+
+		if "" != fig.label {
+			if _, ok := r.figureLabels[fig.label]; ok {
+				logger.Warnf("duplicated figure label [%s]", fig.label)
+			} else {
+				r.figureLabels[fig.label] = fig
+			}
+		}
+		r.figures[n] = fig
+		r.figureList = append(r.figureList, fig)
+		return ast.WalkSkipChildren
+	})
+}
+
+// standaloneImage 判断图片 img 是否独占一个段落，段落中除了图片只能有空白、行级属性和标签标记。
+func standaloneImage(img *ast.Node) bool {
+	if nil == img.Parent || ast.NodeParagraph != img.Parent.Type {
+		return false
+	}
+	for n := img.Parent.FirstChild; nil != n; n = n.Next {
+		switch n.Type {
+		case ast.NodeImage:
+			if n != img {
+				return false
+			}
+		case ast.NodeKramdownSpanIAL, ast.NodeSoftBreak:
+		case ast.NodeText:
+			text := figureLabelMark.ReplaceAllString(util.BytesToStr(n.Tokens), "")
+			if "" != strings.TrimSpace(text) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// figureRefText 返回引用标签 label 的图片时显示的文本，比如 Figure 1，没有该标签时 ok 为 false。
+func (r *PdfRenderer) figureRefText(label string) (text string, ok bool) {
+	fig := r.figureLabels[label]
+	if nil == fig {
+		return "", false
+	}
+	return r.FigureLabel + " " + strconv.Itoa(fig.number), true
+}
+
+// figureAnchor 返回标签 label 对应的图片锚点名。
+func figureAnchor(label string) string {
+	return "fig-" + label
+}
+
+// figureNumberAnchor 返回编号为 number 的图片锚点名，用于图片目录链接。
+func figureNumberAnchor(number int) string {
+	return "figure-" + strconv.Itoa(number)
+}
+
+// figureCaptionLines 返回图片 fig 的题注按照当前盒子宽度折行后的各行文本，fig 为空时返回 nil。
+func (r *PdfRenderer) figureCaptionLines(fig *figure) []string {
+	if nil == fig {
+		return nil
+	}
+	font := r.peekFont()
+	r.pdf.SetFont(font.family, font.style, font.size)
+	box := r.peekBox()
+	lines, err := r.pdf.SplitText(fig.text(r.FigureLabel), box.right-box.left)
+	if nil != err {
+		return []string{fig.text(r.FigureLabel)}
+	}
+	return lines
+}
+
+// figureCaptionHeight 返回题注 lines 占用的高度，包括与图片之间的间距。
+func (r *PdfRenderer) figureCaptionHeight(lines []string) float64 {
+	if 0 == len(lines) {
+		return 0
+	}
+	return 4 + float64(len(lines))*r.lineHeight
+}
+
+// renderFigureAnchor 在图片顶部 top 处设置图片 fig 的锚点，供引用和图片目录跳转。
+func (r *PdfRenderer) renderFigureAnchor(fig *figure, top float64) {
+	y := r.pdf.GetY()
+	r.pdf.SetY(top)
+	r.pdf.SetAnchor(figureNumberAnchor(fig.number))
+	if "" != fig.label {
+		r.pdf.SetAnchor(figureAnchor(fig.label))
+	}
+	r.pdf.SetY(y)
+}
+
+// renderFigureCaption 在当前位置居中输出题注 lines，输出后纵坐标位于最后一行。
+func (r *PdfRenderer) renderFigureCaption(lines []string) {
+	font, textColor := r.peekFont(), r.peekTextColor()
+	r.pdf.SetFont(font.family, font.style, font.size)
+	r.pdf.SetTextColor(textColor.R, textColor.G, textColor.B)
+	box := r.peekBox()
+	r.pdf.SetY(r.pdf.GetY() + 4)
+	for i, line := range lines {
+		if 0 < i {
+			r.br(r.lineHeight)
+		}
+		width, _ := r.pdf.MeasureTextWidth(line)
+		r.pdf.SetX(box.left + math.Max(0, (box.right-box.left-width)/2))
+		r.cell(line)
+		r.LastOut = line[len(line)-1]
+	}
+}
+
+// renderListOfFigures 输出图片目录，每一项链接到对应的图片，然后另起一页。
+func (r *PdfRenderer) renderListOfFigures() {
+	if !r.ListOfFigures || 0 == len(r.figureList) {
+		return
+	}
+
+	r.pushFont(&Font{"bold", "B", int(math.Round(r.heading2Size))})
+	r.WriteString(r.ListOfFiguresTitle)
+	r.popFont()
+	r.pdf.SetY(r.pdf.GetY() + 6)
+	r.Newline()
+	r.pdf.SetY(r.pdf.GetY() + 6)
+
+	r.pushTextColor(&RGB{66, 133, 244})
+	for _, fig := range r.figureList {
+		x, y := r.pdf.GetX(), r.pdf.GetY()
+		r.WriteString(fig.text(r.FigureLabel))
+		if y != r.pdf.GetY() {
+			x, y = r.peekBox().left, r.pdf.GetY()
+		}
+		r.pdf.AddInternalLink(figureNumberAnchor(fig.number), x, y, r.pdf.GetX()-x, r.lineHeight)
+		r.Newline()
+	}
+	r.popTextColor()
+	r.addPage()
+	r.LastOut = lex.ItemNewline
+}
@@ -0,0 +1,56 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+)
+
+func TestNumberFigures(t *testing.T) {
+	md := "![A](a.png){#fig:a}\n\n![B](b.png) {#b}\n\nText ![C](c.png){#fig:c}\n\n![D](d.png)"
+	tree := parse.Parse("", []byte(md), parse.NewOptions())
+	before := dumpTree(tree.Root)
+	r := &PdfRenderer{ImageCaption: true, textOffsets: map[*ast.Node]int{}}
+	r.numberFigures(tree.Root)
+	if after := dumpTree(tree.Root); before != after {
+		t.Errorf("numberFigures modified tree:\n%s\nwant:\n%s", after, before)
+	}
+
+	tests := []struct {
+		label  string
+		number int
+	}{
+		{"fig:a", 1},
+		{"b", 2},
+	}
+	for _, test := range tests {
+		fig := r.figureLabels[test.label]
+		if nil == fig || test.number != fig.number {
+			t.Errorf("figure [%s] = %+v, want number %d", test.label, fig, test.number)
+		}
+	}
+	if _, ok := r.figureLabels["fig:c"]; ok || 3 != len(r.figureList) {
+		t.Errorf("numberFigures numbered %d figures, want 3 without inline image", len(r.figureList))
+	}
+
+	// 独立图片后的标签标记在绘制时跳过，行内图片后的标签标记原样绘制
+	if 2 != len(r.textOffsets) {
+		t.Errorf("textOffsets has %d nodes, want 2", len(r.textOffsets))
+	}
+	for n, offset := range r.textOffsets {
+		if rest := string(n.Tokens[offset:]); "" != rest {
+			t.Errorf("text [%s] draws %q, want empty", n.Tokens, rest)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// formField 描述了交互式复选框表单域。
+type formField struct {
+	page    int     // 所在页码，从 1 开始
+	x, y    float64 // 左上角坐标
+	size    float64 // 边长
+	checked bool    // 是否勾选
+}
+
+var pdfAnnotsPrefix = []byte("/Annots [")
+
+// appendFormFields 通过 PDF 增量更新把表单域 fields 追加到 gopdf 生成的文档 data 中。
+//
+// gopdf 不支持 AcroForm，所以这里在原文档之后追加复选框控件注解、引用这些注解的页面对象以及带 /AcroForm 的目录对象。
+func appendFormFields(data []byte, fields []*formField, pageHeight float64) ([]byte, error) {
+	u, err := newPdfUpdate(data)
+	if nil != err {
+		return nil, err
+	}
+
+	// 所有复选框大小一致，共用勾选和未勾选两个外观流
+	boxSize := fields[0].size
+	onID, offID := u.newID(), u.newID()
+	u.writeObject(onID, checkboxAppearance(boxSize, true))
+	u.writeObject(offID, checkboxAppearance(boxSize, false))
+
+	var fieldRefs []string
+	pageAnnots := map[int][]string{}
+	for i, field := range fields {
+		pageID, err := u.page(field.page)
+		if nil != err {
+			return nil, err
+		}
+		state := "/Off"
+		if field.checked {
+			state = "/Yes"
+		}
+		id := u.newID()
+		ref := fmt.Sprintf("%d 0 R", id)
+		u.writeObject(id, fmt.Sprintf("<<\n  /Type /Annot\n  /Subtype /Widget\n  /FT /Btn\n  /T (task-%d)\n  /V %s\n  /AS %s\n  /F 4\n  /P %d 0 R\n"+
+			"  /Rect [%.2f %.2f %.2f %.2f]\n  /AP << /N << /Yes %d 0 R /Off %d 0 R >> >>\n>>",
+			i+1, state, state, pageID, field.x, pageHeight-field.y-field.size, field.x+field.size, pageHeight-field.y, onID, offID))
+		fieldRefs = append(fieldRefs, ref)
+		pageAnnots[pageID] = append(pageAnnots[pageID], ref)
+	}
+
+	for _, pageID := range u.pages {
+		annots := pageAnnots[pageID]
+		if 1 > len(annots) {
+			continue
+		}
+		dict, err := u.objectDict(pageID)
+		if nil != err {
+			return nil, err
+		}
+		refs := " " + strings.Join(annots, " ") + " "
+		if idx := bytes.Index(dict, pdfAnnotsPrefix); 0 <= idx {
+			idx += len(pdfAnnotsPrefix)
+			dict = append(dict[:idx:idx], append([]byte(refs), dict[idx:]...)...)
+		} else {
+			dict = append(dict, []byte("  /Annots ["+refs+"]\n")...)
+		}
+		u.writeObject(pageID, "<<"+string(dict)+">>")
+	}
+
+	catalog, err := u.objectDict(u.root)
+	if nil != err {
+		return nil, err
+	}
+	u.writeObject(u.root, "<<"+string(catalog)+"  /AcroForm << /Fields ["+strings.Join(fieldRefs, " ")+"] >>\n>>")
+	return u.bytes(), nil
+}
+
+// checkboxAppearance 返回边长为 size 的复选框外观流对象，样式和非交互模式下绘制的复选框一致。
+func checkboxAppearance(size float64, checked bool) string {
+	var stream string
+	if checked {
+		stream = fmt.Sprintf("q 0.259 0.522 0.957 rg 0 0 %.2f %.2f re f 1 1 1 RG %.2f w %.2f %.2f m %.2f %.2f l %.2f %.2f l S Q",
+			size, size, size/8, size*0.22, size*0.48, size*0.42, size*0.28, size*0.78, size*0.7)
+	} else {
+		stream = fmt.Sprintf("q 0.416 0.451 0.490 RG 1 w 0.5 0.5 %.2f %.2f re S Q", size-1, size-1)
+	}
+	return fmt.Sprintf("<<\n  /Type /XObject\n  /Subtype /Form\n  /BBox [0 0 %.2f %.2f]\n  /Length %d\n>>\nstream\n%s\nendstream",
+		size, size, len(stream), stream)
+}
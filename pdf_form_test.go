@@ -0,0 +1,113 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAppendFormFields(t *testing.T) {
+	r := newTestRenderer(t, "- [x] done\n- [ ] todo\n")
+	r.TaskListFormField = true
+	r.pdf.AddPage()
+	r.Render()
+	if 2 != len(r.formFields) {
+		t.Fatalf("got %d form fields, want 2", len(r.formFields))
+	}
+	data, err := r.pdf.GetBytesPdfReturnErr()
+	if nil != err {
+		t.Fatal(err)
+	}
+	r.pdf.Close()
+	updated, err := appendFormFields(data, r.formFields, r.pageSize.H)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(updated, data) {
+		t.Fatal("original document modified")
+	}
+
+	offsets, trailer := lastXref(t, updated)
+	object := func(id int) string {
+		offset, ok := offsets[id]
+		if !ok {
+			t.Fatalf("object [%d] not in xref", id)
+		}
+		obj := string(updated[offset:])
+		return obj[:strings.Index(obj, "endobj")]
+	}
+	for id, offset := range offsets {
+		if offset < len(data) {
+			t.Errorf("object [%d] at %d is not appended", id, offset)
+		}
+		if header := fmt.Sprintf("%d 0 obj\n", id); !bytes.HasPrefix(updated[offset:], []byte(header)) {
+			t.Errorf("xref offset %d of object [%d] points at %q", offset, id, updated[offset:offset+len(header)])
+		}
+	}
+	if !strings.Contains(trailer, "/Prev ") {
+		t.Errorf("trailer %q has no /Prev", trailer)
+	}
+
+	root := pdfTrailerRoot.FindStringSubmatch(trailer)
+	if nil == root {
+		t.Fatalf("trailer %q has no /Root", trailer)
+	}
+	rootID, _ := strconv.Atoi(root[1])
+	fields := regexp.MustCompile(`/AcroForm << /Fields \[([^\]]*)\] >>`).FindStringSubmatch(object(rootID))
+	if nil == fields {
+		t.Fatalf("catalog has no /AcroForm:\n%s", object(rootID))
+	}
+	refs := pdfObjectRef.FindAllStringSubmatch(fields[1], -1)
+	if 2 != len(refs) {
+		t.Fatalf("/AcroForm references %d fields, want 2", len(refs))
+	}
+	for i, ref := range refs {
+		id, _ := strconv.Atoi(ref[1])
+		widget := object(id)
+		if !strings.Contains(widget, "/Subtype /Widget") {
+			t.Errorf("field [%d] is not a widget:\n%s", id, widget)
+		}
+		state := "/Off"
+		if 0 == i {
+			state = "/Yes"
+		}
+		if !strings.Contains(widget, "/V "+state) {
+			t.Errorf("field [%d] is not %s:\n%s", id, state, widget)
+		}
+		page := regexp.MustCompile(`/P (\d+) 0 R`).FindStringSubmatch(widget)
+		if nil == page {
+			t.Fatalf("field [%d] has no page", id)
+		}
+		pageID, _ := strconv.Atoi(page[1])
+		if !strings.Contains(object(pageID), " "+ref[0]+" ") {
+			t.Errorf("page [%d] does not annotate field [%d]:\n%s", pageID, id, object(pageID))
+		}
+	}
+}
+
+func TestAppendFormFieldsMalformed(t *testing.T) {
+	fields := []*formField{{page: 1, x: 10, y: 10, size: 10}}
+	tests := []string{
+		"not a pdf",
+		"%PDF-1.4\ntrailer\n<<\n/Size 3\n/Root 1 0 R\n>>\nstartxref\n9\n%%EOF\n",                                                      // 没有页面树
+		"%PDF-1.4\n2 0 obj\n<< /Type /Pages /Kids [ 3 0 R ] >>\nendobj\ntrailer\n<<\n/Size 4\n/Root 1 0 R\n>>\nstartxref\n9\n%%EOF\n", // 缺少页面对象
+	}
+	for _, test := range tests {
+		if data, err := appendFormFields([]byte(test), fields, 100); nil == err {
+			t.Errorf("appendFormFields(%q) = %q, want error", test, data)
+		}
+	}
+}
@@ -0,0 +1,545 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/util"
+	_ "golang.org/x/image/bmp"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// 无法解码的图片使用占位框代替，占位框的自然尺寸。
+const (
+	imgPlaceholderWidth  = 200
+	imgPlaceholderHeight = 100
+)
+
+// PdfImageFailure 描述了一张加载失败的图片。
+type PdfImageFailure struct {
+	Src    string // 图片地址
+	Reason string // 失败原因
+}
+
+// addImgFailure 记录加载失败的图片 src 及原因 err，同一地址只记录一次。
+func (r *PdfRenderer) addImgFailure(src string, err error) {
+	src = shortImgSrc(src)
+	for _, failure := range r.imgFailures {
+		if src == failure.Src {
+			return
+		}
+	}
+	r.imgFailures = append(r.imgFailures, &PdfImageFailure{Src: src, Reason: err.Error()})
+}
+
+// ImageFailures 返回加载失败的图片，按照失败的先后顺序排列。
+func (r *PdfRenderer) ImageFailures() []*PdfImageFailure {
+	return r.imgFailures
+}
+
+// ImageFailureReport 返回加载失败图片的汇总报告，每张图片一行，没有失败时返回空字符串。
+func (r *PdfRenderer) ImageFailureReport() string {
+	if 0 == len(r.imgFailures) {
+		return ""
+	}
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "%d image(s) failed to load:", len(r.imgFailures))
+	for _, failure := range r.imgFailures {
+		fmt.Fprintf(buf, "\n  [%s]: %s", failure.Src, failure.Reason)
+	}
+	return buf.String()
+}
+
+// embeddableImg 判断格式为 format 的图片数据 data 能否直接嵌入 PDF。
+//
+// gopdf 只支持 JPEG 和 8 位非隔行扫描的 PNG，其他格式（GIF、BMP、TIFF、WebP 等）需要重新编码。
+func embeddableImg(data []byte, format string) bool {
+	switch format {
+	case "jpeg":
+		return true
+	case "png":
+		// IHDR 块中第 24 字节为位深，第 28 字节为隔行扫描方式
+		return 29 <= len(data) && 16 != data[24] && 0 == data[28]
+	}
+	return false
+}
+
+// reencodeImg 解码图片数据 data 并重新编码为 PNG，动图只保留第一帧。
+func reencodeImg(data []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if nil != err {
+		return nil, err
+	}
+	// 转换为 8 位 NRGBA，避免 16 位色深和调色板透明度带来的兼容问题
+	rgba := image.NewNRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	buf := &bytes.Buffer{}
+	if err = png.Encode(buf, rgba); nil != err {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// optimizedImg 描述了按照输出选项处理后的图片。
+type optimizedImg struct {
+	width      int    // 处理后的像素宽度
+	downscaled bool   // 是否经过降采样，没有降采样时处理结果适用于任意输出尺寸
+	data       []byte // 处理后的图片数据
+}
+
+// defaultJPEGQuality 是没有设置 ImageJPEGQuality 时 JPEG 图片降采样或者旋转后重新编码的质量。
+const defaultJPEGQuality = 90
+
+// photoColors 是判断照片的颜色数阈值，不同颜色超过该数量的不透明图片视为照片。
+const photoColors = 8192
+
+// optimizeImg 按照 ImageMaxDPI、ImageJPEGQuality 和 ImageGrayscale 处理将以 width（pt）宽度输出的图片数据 data，没有开启这些选项时原样返回。
+//
+// 处理结果按照图片内容缓存，内容相同的图片（即使地址不同）只处理一次并输出相同的数据，gopdf 按照数据摘要只嵌入一次；
+// 同一图片以更大的尺寸再次输出时重新处理。
+func (r *PdfRenderer) optimizeImg(data []byte, width float64) []byte {
+	if 0 >= r.ImageMaxDPI && 0 >= r.ImageJPEGQuality && !r.ImageGrayscale {
+		return data
+	}
+
+	maxPx := math.MaxInt32
+	if 0 < r.ImageMaxDPI {
+		maxPx = int(math.Max(1, math.Ceil(width*r.ImageMaxDPI/72)))
+	}
+	sum := sha1.Sum(data)
+	key := hex.EncodeToString(sum[:])
+	if cached := r.optimizedImgs[key]; nil != cached && (!cached.downscaled || cached.width >= maxPx) {
+		return cached.data
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if nil != err {
+		return data
+	}
+	ret := &optimizedImg{width: img.Bounds().Dx(), data: data}
+	changed := false
+	if bounds := img.Bounds(); bounds.Dx() > maxPx {
+		height := int(math.Max(1, math.Round(float64(bounds.Dy())*float64(maxPx)/float64(bounds.Dx()))))
+		dst := image.NewRGBA(image.Rect(0, 0, maxPx, height))
+		xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, xdraw.Src, nil)
+		img, ret.width, ret.downscaled, changed = dst, maxPx, true, true
+	}
+	opaque := opaqueImg(img)
+	if r.ImageGrayscale {
+		img, changed = grayImg(img, opaque), true
+	}
+
+	buf := &bytes.Buffer{}
+	switch {
+	case 0 < r.ImageJPEGQuality && opaque && ("jpeg" == format || photoImg(img)):
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: r.ImageJPEGQuality})
+	case changed && "jpeg" == format:
+		// JPEG 图片降采样或者转换灰度后仍使用 JPEG，避免编码为 PNG 后体积变大
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: defaultJPEGQuality})
+	case changed:
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(buf, img)
+	}
+	if nil != err {
+		logger.Warnf("optimize image failed: %s", err)
+	} else if 0 < buf.Len() && (changed || buf.Len() < len(data)) {
+		// 没有降采样和灰度转换时，重新压缩后变大则保留原图
+		ret.data = buf.Bytes()
+	}
+	r.optimizedImgs[key] = ret
+	return ret.data
+}
+
+// opaqueImg 判断图片 img 是否完全不透明。
+func opaqueImg(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// photoImg 判断图片 img 是否是照片，截图、图表等颜色较少的图片使用 JPEG 压缩会在文字和线条边缘产生明显的噪点。
+func photoImg(img image.Image) bool {
+	colors := map[color.RGBA]struct{}{}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			colors[color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)] = struct{}{}
+			if photoColors < len(colors) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// grayImg 将图片 img 转换为灰度图，不透明时使用 8 位灰度，否则保留透明度。
+func grayImg(img image.Image, opaque bool) image.Image {
+	bounds := img.Bounds()
+	if opaque {
+		gray := image.NewGray(bounds)
+		draw.Draw(gray, bounds, img, bounds.Min, draw.Src)
+		return gray
+	}
+	ret := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			g := color.GrayModel.Convert(color.RGBA{R: c.R, G: c.G, B: c.B, A: 0xFF}).(color.Gray).Y
+			ret.SetNRGBA(x, y, color.NRGBA{R: g, G: g, B: g, A: c.A})
+		}
+	}
+	return ret
+}
+
+// fitImgSize 将自然尺寸为 width × height 的图片等比缩放到 maxWidth × maxHeight 以内，放大倍数不超过 maxScale。
+func fitImgSize(width, height, maxWidth, maxHeight, maxScale float64) (float64, float64) {
+	if 0 >= width || 0 >= height {
+		return width, height
+	}
+	scale := math.Min(maxWidth/width, maxHeight/height)
+	if 0 < maxScale {
+		scale = math.Min(scale, maxScale)
+	}
+	return width * scale, height * scale
+}
+
+// imgDPI 读取 PNG（pHYs 块）或者 JPEG（JFIF 头）图片数据 data 中记录的水平和垂直分辨率，没有记录时返回 0。
+func imgDPI(data []byte) (dpiX, dpiY float64) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return pngDPI(data)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return jpegDPI(data)
+	}
+	return
+}
+
+func pngDPI(data []byte) (dpiX, dpiY float64) {
+	for pos := 8; pos+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		body := data[pos+8:]
+		if "IDAT" == typ || length > len(body) {
+			return
+		}
+		// 单位为 1 时分辨率为每米像素数
+		if "pHYs" == typ && 9 <= length && 1 == body[8] {
+			dpiX = float64(binary.BigEndian.Uint32(body)) * 0.0254
+			dpiY = float64(binary.BigEndian.Uint32(body[4:])) * 0.0254
+			return
+		}
+		pos += 12 + length
+	}
+	return
+}
+
+func jpegDPI(data []byte) (dpiX, dpiY float64) {
+	body := jpegSegment(data, 0xE0, "JFIF\x00")
+	if 12 > len(body) {
+		return
+	}
+	// 单位为 1 时为每英寸点数，为 2 时为每厘米点数
+	x, y := float64(binary.BigEndian.Uint16(body[8:])), float64(binary.BigEndian.Uint16(body[10:]))
+	switch body[7] {
+	case 1:
+		return x, y
+	case 2:
+		return x * 2.54, y * 2.54
+	}
+	return
+}
+
+// jpegSegment 在 JPEG 图片数据 data 的图像数据之前查找标记为 marker 且内容以 prefix 开头的第一个段，返回段的内容，没有找到或者数据不完整时返回 nil。
+func jpegSegment(data []byte, marker byte, prefix string) []byte {
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xD8}) {
+		return nil
+	}
+	for pos := 2; pos+1 < len(data); {
+		if 0xFF != data[pos] {
+			return nil
+		}
+		m := data[pos+1]
+		switch {
+		case 0xFF == m: // 填充字节
+			pos++
+			continue
+		case 0x01 == m || 0xD0 <= m && 0xD8 >= m: // 没有长度的独立标记
+			pos += 2
+			continue
+		case 0xD9 == m || 0xDA == m: // 图像结束或者图像数据开始
+			return nil
+		}
+		if pos+4 > len(data) {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if 2 > length || pos+2+length > len(data) {
+			return nil
+		}
+		body := data[pos+4 : pos+2+length]
+		if marker == m && bytes.HasPrefix(body, []byte(prefix)) {
+			return body
+		}
+		pos += 2 + length
+	}
+	return nil
+}
+
+// jpegOrientation 读取 JPEG 图片数据 data 的 EXIF 方向标签（1-8），没有记录时返回 0。
+func jpegOrientation(data []byte) int {
+	body := jpegSegment(data, 0xE1, "Exif\x00\x00")
+	if nil == body {
+		return 0
+	}
+	return exifOrientation(body[6:])
+}
+
+// exifOrientation 从 TIFF 格式的 EXIF 数据 tiff 的第一个 IFD 中读取方向标签（0x0112）。
+func exifOrientation(tiff []byte) int {
+	if 8 > len(tiff) {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if 0 > ifd || ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		// 方向标签的类型为 SHORT，值保存在值域的前两个字节中
+		if 0x0112 == order.Uint16(tiff[entry:]) && 3 == order.Uint16(tiff[entry+2:]) {
+			if orientation := int(order.Uint16(tiff[entry+8:])); 1 <= orientation && 8 >= orientation {
+				return orientation
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// orientImg 按照 EXIF 方向 orientation 旋转或者翻转 JPEG 图片数据 data，返回重新编码的 JPEG 数据。
+//
+// 方向 5-8 会交换宽高，PDF 阅读器不会读取 EXIF，所以需要在嵌入前转换为正向。
+func orientImg(data []byte, orientation int) ([]byte, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if nil != err {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	dstW, dstH := w, h
+	if 5 <= orientation {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := x, y
+			switch orientation {
+			case 2: // 水平翻转
+				dx = w - 1 - x
+			case 3: // 旋转 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dy = h - 1 - y
+			case 5: // 沿主对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转 90°
+				dx, dy = h-1-y, x
+			case 7: // 沿副对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转 90°
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err = jpeg.Encode(buf, dst, &jpeg.Options{Quality: defaultJPEGQuality}); nil != err {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// inlineImage 判断图片 img 是否随文本行内排版。
+//
+// 图片（或者只包含该图片的链接）所在的段落、标题、表格单元格等容器中还有文本或者其他图片时为行内图片，比如句子中的徽章和图标；独占容器的图片按照块级排版。
+func inlineImage(img *ast.Node) bool {
+	n := img
+	if nil != n.Parent && ast.NodeLink == n.Parent.Type {
+		n = n.Parent
+		for c := n.FirstChild; nil != c; c = c.Next {
+			switch c.Type {
+			case ast.NodeOpenBracket, ast.NodeCloseBracket, ast.NodeOpenParen, ast.NodeCloseParen, ast.NodeLinkDest, ast.NodeLinkSpace, ast.NodeLinkTitle:
+			case ast.NodeText:
+				if "" != strings.TrimSpace(util.BytesToStr(c.Tokens)) {
+					return true
+				}
+			default:
+				if c != img {
+					return true
+				}
+			}
+		}
+	}
+	if nil == n.Parent {
+		return false
+	}
+
+	for c := n.Parent.FirstChild; nil != c; c = c.Next {
+		switch c.Type {
+		case ast.NodeKramdownSpanIAL, ast.NodeSoftBreak, ast.NodeHeadingC8hMarker:
+		case ast.NodeText:
+			text := figureLabelMark.ReplaceAllString(util.BytesToStr(c.Tokens), "")
+			if "" != strings.TrimSpace(text) {
+				return true
+			}
+		default:
+			if c != n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// imgAttrs 描述了图片的显示尺寸和对齐方式提示。
+type imgAttrs struct {
+	width  string // 宽度：数字（像素）、带单位（px、pt、mm、cm、in）的长度或者相对内容宽度的百分比
+	height string // 高度，格式同宽度，百分比相对内容区域高度
+	align  string // 对齐方式：left、center、right，为空时跟随当前位置
+}
+
+// imgSizeSuffix 匹配标题中的 =WxH 尺寸后缀，宽高都可以省略其一，比如 =300x、=x200。
+var imgSizeSuffix = regexp.MustCompile(`^=(\d*(?:\.\d+)?[a-z%]*)x(\d*(?:\.\d+)?[a-z%]*)$`)
+
+// parseImgAttrs 从图片节点 img 的 Kramdown 行级属性（{: width="50%" align="center"}）和标题中解析尺寸和对齐方式，属性优先。
+//
+// 标题中可以使用 =WxH 后缀或者 width=、height=、align= 键值对，这些内容不会作为标题输出。
+func parseImgAttrs(img *ast.Node) (ret *imgAttrs, title string) {
+	ret = &imgAttrs{}
+	if titleNode := img.ChildByType(ast.NodeLinkTitle); nil != titleNode {
+		var rest []string
+		for _, field := range strings.Fields(util.BytesToStr(titleNode.Tokens)) {
+			if groups := imgSizeSuffix.FindStringSubmatch(field); nil != groups {
+				ret.width, ret.height = groups[1], groups[2]
+				continue
+			}
+			if kv := strings.SplitN(field, "=", 2); 2 == len(kv) && ret.set(kv[0], kv[1]) {
+				continue
+			}
+			rest = append(rest, field)
+		}
+		title = strings.Join(rest, " ")
+	}
+	for _, kv := range img.KramdownIAL {
+		ret.set(kv[0], kv[1])
+	}
+	return
+}
+
+// set 设置属性 key 的值 value，key 不是尺寸或者对齐方式时返回 false。
+func (attrs *imgAttrs) set(key, value string) bool {
+	value = strings.Trim(value, `"'`)
+	switch strings.ToLower(key) {
+	case "width":
+		attrs.width = value
+	case "height":
+		attrs.height = value
+	case "align":
+		attrs.align = strings.ToLower(value)
+	default:
+		return false
+	}
+	return true
+}
+
+// imgLength 将长度 value 转换为 pt，百分比相对于 base，没有单位时按照 CSS 像素（0.75pt）处理。
+func imgLength(value string, base float64) (float64, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	units := []struct {
+		suffix string
+		scale  float64
+	}{{"%", base / 100}, {"px", 0.75}, {"pt", 1}, {"mm", 72 / 25.4}, {"cm", 72 / 2.54}, {"in", 72}, {"", 0.75}}
+	for _, unit := range units {
+		if !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+		num, err := strconv.ParseFloat(strings.TrimSuffix(value, unit.suffix), 64)
+		if nil != err || 0 >= num {
+			return 0, false
+		}
+		return num * unit.scale, true
+	}
+	return 0, false
+}
+
+// imgDisplaySize 根据尺寸提示 attrs 计算自然尺寸为 width × height 的图片的显示尺寸。
+//
+// 没有尺寸提示时按照自然尺寸缩放到 maxWidth × maxHeight 以内，放大倍数不超过 ImageMaxScale；
+// 指定了宽度或者高度时按照指定的尺寸显示（只指定其一时等比缩放），只在超出 maxWidth 时等比缩小。
+func (r *PdfRenderer) imgDisplaySize(attrs *imgAttrs, width, height, maxWidth, maxHeight float64) (float64, float64) {
+	if 0 >= width || 0 >= height {
+		return width, height
+	}
+
+	scaleX, okX := imgLength(attrs.width, maxWidth)
+	scaleY, okY := imgLength(attrs.height, maxHeight)
+	scaleX, scaleY = scaleX/width, scaleY/height
+	switch {
+	case okX && !okY:
+		scaleY = scaleX
+	case !okX && okY:
+		scaleX = scaleY
+	case !okX && !okY:
+		return fitImgSize(width, height, maxWidth, maxHeight, r.ImageMaxScale)
+	}
+
+	width, height = width*scaleX, height*scaleY
+	if fit := maxWidth / width; 1 > fit {
+		width, height = width*fit, height*fit
+	}
+	return width, height
+}
@@ -0,0 +1,250 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// 测试图片四个象限的颜色：左上、右上、左下、右下
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+	white = color.RGBA{255, 255, 255, 255}
+)
+
+// newQuadrantJPEG 创建 width × height 的四色 JPEG 图片，orientation 大于 0 时写入 EXIF 方向标签，order 为 TIFF 字节序。
+func newQuadrantJPEG(t *testing.T, width, height, orientation int, order binary.ByteOrder) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := red
+			switch {
+			case x >= width/2 && y < height/2:
+				c = green
+			case x < width/2 && y >= height/2:
+				c = blue
+			case x >= width/2 && y >= height/2:
+				c = white
+			}
+			img.Set(x, y, c)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 100}); nil != err {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if 1 > orientation {
+		return data
+	}
+
+	// TIFF 头、两个 IFD 条目（方向标签前放一个图片描述标签），值域不足 4 字节时靠前存放
+	tiff := &bytes.Buffer{}
+	if binary.LittleEndian == order {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+	write := func(v interface{}) { binary.Write(tiff, order, v) }
+	write(uint16(42))
+	write(uint32(8))
+	write(uint16(2))
+	write([]uint16{0x010E, 2, 0, 4})
+	tiff.WriteString("abc\x00")
+	write([]uint16{0x0112, 3, 0, 1, uint16(orientation), 0})
+	write(uint32(0))
+
+	app1 := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+len(app1)))
+	segment = append(segment, app1...)
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+// quadrantColors 返回图片四个象限中心的颜色：左上、右上、左下、右下。
+func quadrantColors(img image.Image) (ret [4]color.Color) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	for i, p := range []image.Point{{w / 4, h / 4}, {w * 3 / 4, h / 4}, {w / 4, h * 3 / 4}, {w * 3 / 4, h * 3 / 4}} {
+		ret[i] = img.At(b.Min.X+p.X, b.Min.Y+p.Y)
+	}
+	return
+}
+
+// similarColor 判断颜色 a 和 b 是否近似，JPEG 有损压缩会带来少量误差。
+func similarColor(a, b color.Color) bool {
+	r1, g1, b1, _ := a.RGBA()
+	r2, g2, b2, _ := b.RGBA()
+	diff := func(x, y uint32) bool { return x>>8 > y>>8+48 || y>>8 > x>>8+48 }
+	return !diff(r1, r2) && !diff(g1, g2) && !diff(b1, b2)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for orientation := 0; orientation <= 8; orientation++ {
+			data := newQuadrantJPEG(t, 8, 4, orientation, order)
+			if got := jpegOrientation(data); orientation != got {
+				t.Errorf("jpegOrientation(%s, %d) = %d", order, orientation, got)
+			}
+		}
+	}
+
+	// 超出范围的值、截断的数据以及非 JPEG 数据
+	data := newQuadrantJPEG(t, 8, 4, 9, binary.BigEndian)
+	if got := jpegOrientation(data); 0 != got {
+		t.Errorf("jpegOrientation(9) = %d, want 0", got)
+	}
+	if got := jpegOrientation(data[:30]); 0 != got {
+		t.Errorf("jpegOrientation(truncated) = %d, want 0", got)
+	}
+	// 独立的 RST 标记后跟着解码器会跳过的无效字节，段长度小于 2
+	if got := jpegOrientation([]byte{0xFF, 0xD8, 0xFF, 0xD0, 0x00, 0x00, 0x00, 0x00}); 0 != got {
+		t.Errorf("jpegOrientation(restart marker) = %d, want 0", got)
+	}
+	if got := jpegOrientation([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x00, 0x00, 0x00}); 0 != got {
+		t.Errorf("jpegOrientation(zero length) = %d, want 0", got)
+	}
+	if got := jpegOrientation([]byte("\x89PNG\r\n\x1a\n")); 0 != got {
+		t.Errorf("jpegOrientation(png) = %d, want 0", got)
+	}
+}
+
+func TestOrientImg(t *testing.T) {
+	// 各个方向转正后四个象限的颜色：左上、右上、左下、右下
+	tests := []struct {
+		orientation int
+		want        [4]color.Color
+	}{
+		{1, [4]color.Color{red, green, blue, white}},
+		{2, [4]color.Color{green, red, white, blue}},
+		{3, [4]color.Color{white, blue, green, red}},
+		{4, [4]color.Color{blue, white, red, green}},
+		{5, [4]color.Color{red, blue, green, white}},
+		{6, [4]color.Color{blue, red, white, green}},
+		{7, [4]color.Color{white, green, blue, red}},
+		{8, [4]color.Color{green, white, red, blue}},
+	}
+	const width, height = 64, 32
+	for _, test := range tests {
+		data := newQuadrantJPEG(t, width, height, test.orientation, binary.BigEndian)
+		oriented, err := orientImg(data, test.orientation)
+		if nil != err {
+			t.Errorf("orientImg(%d) failed: %s", test.orientation, err)
+			continue
+		}
+		img, err := jpeg.Decode(bytes.NewReader(oriented))
+		if nil != err {
+			t.Errorf("decode oriented image %d failed: %s", test.orientation, err)
+			continue
+		}
+
+		wantW, wantH := width, height
+		if 5 <= test.orientation {
+			wantW, wantH = height, width
+		}
+		if b := img.Bounds(); wantW != b.Dx() || wantH != b.Dy() {
+			t.Errorf("orientImg(%d) size = %dx%d, want %dx%d", test.orientation, b.Dx(), b.Dy(), wantW, wantH)
+			continue
+		}
+		got := quadrantColors(img)
+		for i := range got {
+			if !similarColor(test.want[i], got[i]) {
+				t.Errorf("orientImg(%d) quadrant %d = %v, want %v", test.orientation, i, got[i], test.want[i])
+			}
+		}
+	}
+}
+
+func TestGetImgSizeOrientation(t *testing.T) {
+	for orientation := 1; orientation <= 8; orientation++ {
+		r := &PdfRenderer{ImageDPI: 72, imgData: map[string][]byte{}, imgs: map[string][]byte{}}
+		r.imgData["a.jpg"] = newQuadrantJPEG(t, 64, 32, orientation, binary.LittleEndian)
+		width, height, err := r.getImgSize("a.jpg", false)
+		if nil != err {
+			t.Errorf("getImgSize(%d) failed: %s", orientation, err)
+			continue
+		}
+
+		wantW, wantH := 64.0, 32.0
+		if 5 <= orientation {
+			wantW, wantH = 32, 64
+		}
+		if wantW != width || wantH != height {
+			t.Errorf("getImgSize(%d) = %vx%v, want %vx%v", orientation, width, height, wantW, wantH)
+		}
+		// 转正后的数据用于绘制，方向为 1 时不需要转换
+		oriented, ok := r.imgs["a.jpg"]
+		if 1 == orientation {
+			if ok {
+				t.Errorf("getImgSize(1) converted image")
+			}
+			continue
+		}
+		config, err := jpeg.DecodeConfig(bytes.NewReader(oriented))
+		if nil != err || int(wantW) != config.Width || int(wantH) != config.Height {
+			t.Errorf("getImgSize(%d) oriented image = %dx%d, %v, want %vx%v", orientation, config.Width, config.Height, err, wantW, wantH)
+		}
+	}
+}
+
+func TestJPEGDPI(t *testing.T) {
+	jfif := func(unit byte, x, y uint16) []byte {
+		return []byte{0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00, 0x01, 0x02, unit, byte(x >> 8), byte(x), byte(y >> 8), byte(y), 0x00, 0x00}
+	}
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	soi, sos := []byte{0xFF, 0xD8}, []byte{0xFF, 0xDA, 0x00, 0x02}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		dpiX, dpiY float64
+	}{
+		{"inch", join(soi, jfif(1, 300, 150), sos), 300, 150},
+		{"centimeter", join(soi, jfif(2, 100, 100), sos), 254, 254},
+		{"aspect ratio", join(soi, jfif(0, 1, 1), sos), 0, 0},
+		{"fill bytes", join(soi, []byte{0xFF, 0xFF}, jfif(1, 72, 72)), 72, 72},
+		{"standalone markers", join(soi, []byte{0xFF, 0x01, 0xFF, 0xD0}, jfif(1, 72, 72)), 72, 72},
+		{"after other segment", join(soi, []byte{0xFF, 0xE1, 0x00, 0x04, 0x00, 0x00}, jfif(1, 96, 96)), 96, 96},
+		{"after image data", join(soi, sos, jfif(1, 72, 72)), 0, 0},
+		{"zero length", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x00, 0x00, 0x00}, 0, 0},
+		{"one length", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x01, 0x00, 0x00}, 0, 0},
+		{"junk after restart marker", []byte{0xFF, 0xD8, 0xFF, 0xD0, 0x00, 0x00, 0x00, 0x00}, 0, 0},
+		{"truncated", join(soi, jfif(1, 72, 72))[:12], 0, 0},
+		{"truncated marker", []byte{0xFF, 0xD8, 0xFF}, 0, 0},
+	}
+	for _, test := range tests {
+		if dpiX, dpiY := imgDPI(test.data); test.dpiX != dpiX || test.dpiY != dpiY {
+			t.Errorf("imgDPI(%s) = %v, %v, want %v, %v", test.name, dpiX, dpiY, test.dpiX, test.dpiY)
+		}
+	}
+}
+
+func TestGetImgSizeMalformedJPEG(t *testing.T) {
+	// 图片开头是独立的 RST 标记和解码器会跳过的无效字节，解码器能够解码，读取分辨率和方向时不能越界
+	data := newQuadrantJPEG(t, 64, 32, 0, binary.BigEndian)
+	data = append([]byte{0xFF, 0xD8, 0xFF, 0xD0, 0x00, 0x00}, data[2:]...)
+	if _, err := jpeg.DecodeConfig(bytes.NewReader(data)); nil != err {
+		t.Skipf("decoder rejects test image: %s", err)
+	}
+
+	r := &PdfRenderer{ImageDPI: 72, imgData: map[string][]byte{"a.jpg": data}, imgs: map[string][]byte{}}
+	width, height, err := r.getImgSize("a.jpg", true)
+	if nil != err || 64 != width || 32 != height {
+		t.Errorf("getImgSize() = %vx%v, %v, want 64x32", width, height, err)
+	}
+}
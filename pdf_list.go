@@ -0,0 +1,111 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/88250/lute/ast"
+)
+
+// listItemMarker 返回列表项 listItem 的标识符，无序列表按嵌套层级选择符号，有序列表按嵌套层级选择编号样式并保留原始分隔符。
+func (r *PdfRenderer) listItemMarker(listItem *ast.Node) string {
+	level := r.listLevel(listItem.Parent)
+	if 0 != listItem.ListData.BulletChar {
+		if 1 > len(r.ListBullets) {
+			return "●"
+		}
+		return r.ListBullets[(level-1)%len(r.ListBullets)]
+	}
+
+	style := "1"
+	if 0 < len(r.ListNumberStyles) {
+		style = r.ListNumberStyles[(level-1)%len(r.ListNumberStyles)]
+	}
+	delimiter := "."
+	if ')' == listItem.ListData.Delimiter {
+		delimiter = ")"
+	}
+	return formatListNumber(listItem.ListData.Num, style) + delimiter
+}
+
+// listMarkerMaxWidth 返回列表 list 中所有列表项标识符的最大宽度，用于右对齐有序列表序号。
+func (r *PdfRenderer) listMarkerMaxWidth(list *ast.Node) (ret float64) {
+	for item := list.FirstChild; nil != item; item = item.Next {
+		if ast.NodeListItem != item.Type {
+			continue
+		}
+		if width, _ := r.pdf.MeasureTextWidth(r.listItemMarker(item)); width > ret {
+			ret = width
+		}
+	}
+	return
+}
+
+// listLevel 返回列表 list 在同类（有序或者无序）列表中的嵌套层级，从 1 开始。
+func (r *PdfRenderer) listLevel(list *ast.Node) (ret int) {
+	ordered := 0 == list.ListData.BulletChar
+	for n := list; nil != n; n = n.Parent {
+		if ast.NodeList == n.Type && ordered == (0 == n.ListData.BulletChar) {
+			ret++
+		}
+	}
+	return
+}
+
+// formatListNumber 按样式 style 格式化有序列表序号 num，style 可选 1（数字）、a/A（字母）和 i/I（罗马数字）。
+func formatListNumber(num int, style string) string {
+	if 1 > num {
+		return strconv.Itoa(num)
+	}
+
+	switch style {
+	case "a":
+		return alphaNumber(num)
+	case "A":
+		return strings.ToUpper(alphaNumber(num))
+	case "i":
+		return romanNumber(num)
+	case "I":
+		return strings.ToUpper(romanNumber(num))
+	default:
+		return strconv.Itoa(num)
+	}
+}
+
+// alphaNumber 将 num 转换为 a、b、...、z、aa、ab 形式的字母序号。
+func alphaNumber(num int) (ret string) {
+	for 0 < num {
+		num--
+		ret = string(rune('a'+num%26)) + ret
+		num /= 26
+	}
+	return
+}
+
+// romanNumber 将 num 转换为小写罗马数字，超过 3999 时使用阿拉伯数字。
+func romanNumber(num int) string {
+	if 3999 < num {
+		return strconv.Itoa(num)
+	}
+
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	buf := strings.Builder{}
+	for i, value := range values {
+		for value <= num {
+			buf.WriteString(symbols[i])
+			num -= value
+		}
+	}
+	return buf.String()
+}
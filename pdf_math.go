@@ -0,0 +1,805 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/signintech/gopdf"
+)
+
+// mathBox 描述了公式排版后的盒子，原点位于盒子左侧基线上，纵坐标向下为正。
+type mathBox struct {
+	width  float64 // 宽度
+	height float64 // 基线以上的高度
+	depth  float64 // 基线以下的深度
+	x, y   float64 // 在父盒子中的偏移
+	class  int     // 原子类别，间距为 -1
+
+	text   string  // 文本
+	family string  // 文本字体
+	size   float64 // 文本字号
+	color  *RGB    // 颜色，为空时继承父盒子
+	link   string  // 内部链接锚点
+
+	paths    []*mathPath // 分数线、根号、可伸缩定界符等矢量线条
+	children []*mathBox  // 子盒子
+}
+
+// mathPath 描述了公式中的矢量线条。
+type mathPath struct {
+	points []gopdf.Point // 折线顶点，curve 为 true 时每 4 个点为一段三次贝塞尔曲线
+	width  float64       // 线宽
+	curve  bool          // 是否是曲线
+	fill   bool          // 是否填充
+}
+
+// mathStyle 描述了公式排版样式。
+type mathStyle struct {
+	base    float64 // 正文字号
+	level   int     // 0 正文，1 上下标，2 二级上下标
+	display bool    // 是否是行间公式样式
+}
+
+func (s mathStyle) size() float64 {
+	return s.base * []float64{1, 0.7, 0.5}[s.level]
+}
+
+// script 返回上下标样式。
+func (s mathStyle) script() mathStyle {
+	return mathStyle{base: s.base, level: int(math.Min(2, float64(s.level+1)))}
+}
+
+// fraction 返回分子分母样式。
+func (s mathStyle) fraction() mathStyle {
+	if s.display {
+		return mathStyle{base: s.base, level: s.level}
+	}
+	return s.script()
+}
+
+// mathSpacing 为原子之间的间距（单位为 1/18 em），负数表示上下标中不加间距。
+var mathSpacing = [8][8]int{
+	mathOrd:   {0, 3, -4, -5, 0, 0, 0, -3},
+	mathOp:    {3, 3, 0, -5, 0, 0, 0, -3},
+	mathBin:   {-4, -4, 0, 0, -4, 0, 0, -4},
+	mathRel:   {-5, -5, 0, 0, -5, 0, 0, -5},
+	mathOpen:  {0, 0, 0, 0, 0, 0, 0, 0},
+	mathClose: {0, 3, -4, -5, 0, 0, 0, -3},
+	mathPunct: {-3, -3, 0, -3, -3, -3, -3, -3},
+	mathInner: {-3, 3, -4, -5, -3, 0, -3, -3},
+}
+
+// layoutMath 排版 TeX 公式 tex，display 为 true 时按照行间公式排版。
+func (r *PdfRenderer) layoutMath(tex string, display bool) *mathBox {
+	style := mathStyle{base: float64(r.fontSize), display: display}
+	nodes, _ := parseMath(tex)
+	return r.layoutMathList(nodes, style)
+}
+
+// renderInlineMathBox 在当前位置输出行内公式盒子 box，放不下时先换行。
+func (r *PdfRenderer) renderInlineMathBox(box *mathBox) {
+	if r.pdf.GetY() > r.pageSize.H-r.margin*2 {
+		r.addPage()
+	}
+	x := r.pdf.GetX()
+	if x+box.width > r.peekBox().right && x > r.peekBox().left {
+		r.br(float64(r.fontSize) + 2)
+		x = r.pdf.GetX()
+	}
+
+	y := r.pdf.GetY()
+	r.drawMathBox(box, x, y+r.ascent*float64(r.fontSize), r.peekTextColor())
+	r.pdf.SetY(y)
+	r.pdf.SetX(x + box.width)
+	r.LastOut = '$'
+}
+
+// renderMathBlockBox 居中输出行间公式盒子 box，eq 不为空时在右侧输出编号。
+func (r *PdfRenderer) renderMathBlockBox(box *mathBox, eq *mathEquation) {
+	r.Newline()
+	top := r.pdf.GetY() + 6
+	if top+box.height+box.depth > r.pageSize.H-r.margin*2 {
+		r.addPage()
+		top = r.pdf.GetY()
+	}
+	parent := r.peekBox()
+	x := parent.left + math.Max(0, (parent.right-parent.left-box.width)/2)
+	if tagWidth := r.equationTagWidth(eq); 0 < tagWidth {
+		// 公式和编号之间至少保留一个字的间距
+		x = math.Max(parent.left, math.Min(x, parent.right-tagWidth-float64(r.fontSize)-box.width))
+	}
+	r.drawMathBox(box, x, top+box.height, r.peekTextColor())
+	if nil != eq {
+		r.renderEquationTag(eq, box, top, top+box.height)
+	}
+	r.pdf.SetY(top + box.height + box.depth + 6)
+	r.LastOut = '$'
+	r.Newline()
+}
+
+// drawMathBox 以 (x, baseline) 为原点绘制公式盒子 box。
+func (r *PdfRenderer) drawMathBox(box *mathBox, x, baseline float64, color *RGB) {
+	x += box.x
+	baseline += box.y
+	if nil != box.color {
+		color = box.color
+	}
+
+	if "" != box.text {
+		r.pdf.SetFont(box.family, mathFontStyle(box.family), box.size)
+		r.pdf.SetTextColor(color.R, color.G, color.B)
+		r.pdf.SetX(x)
+		r.pdf.SetY(baseline)
+		r.pdf.Text(box.text)
+	}
+
+	for _, path := range box.paths {
+		var points []gopdf.Point
+		for _, p := range path.points {
+			points = append(points, gopdf.Point{X: x + p.X, Y: baseline + p.Y})
+		}
+		r.pdf.SetStrokeColor(color.R, color.G, color.B)
+		r.pdf.SetFillColor(color.R, color.G, color.B)
+		r.pdf.SetLineWidth(path.width)
+		switch {
+		case path.fill:
+			r.pdf.Polygon(points, "F")
+		case path.curve:
+			for i := 0; i+3 < len(points); i += 4 {
+				r.pdf.Curve(points[i].X, points[i].Y, points[i+1].X, points[i+1].Y, points[i+2].X, points[i+2].Y, points[i+3].X, points[i+3].Y, "D")
+			}
+		default:
+			for i := 1; i < len(points); i++ {
+				r.pdf.Line(points[i-1].X, points[i-1].Y, points[i].X, points[i].Y)
+			}
+		}
+		r.pdf.SetLineWidth(1)
+		r.pdf.SetStrokeColor(0, 0, 0)
+	}
+
+	for _, child := range box.children {
+		r.drawMathBox(child, x, baseline, color)
+	}
+
+	if "" != box.link {
+		r.pdf.AddInternalLink(box.link, x, baseline-box.height, box.width, box.height+box.depth)
+	}
+
+	font := r.peekFont()
+	r.pdf.SetFont(font.family, font.style, font.size)
+	textColor := r.peekTextColor()
+	r.pdf.SetTextColor(textColor.R, textColor.G, textColor.B)
+}
+
+func mathFontStyle(family string) string {
+	switch family {
+	case "bold":
+		return "B"
+	case "italic":
+		return "I"
+	default:
+		return "R"
+	}
+}
+
+// layoutMathList 水平排列节点列表 nodes，并按照原子类别插入间距。
+func (r *PdfRenderer) layoutMathList(nodes []*mathNode, style mathStyle) *mathBox {
+	var items []*mathBox
+	for _, n := range nodes {
+		if mathNodeStyle == n.kind {
+			switch n.text {
+			case "display":
+				style = mathStyle{base: style.base, display: true}
+			case "text":
+				style = mathStyle{base: style.base}
+			case "script":
+				style = mathStyle{base: style.base, level: 1}
+			case "scriptscript":
+				style = mathStyle{base: style.base, level: 2}
+			}
+			continue
+		}
+		items = append(items, r.layoutMathNode(n, style))
+	}
+
+	// 二元运算符前后没有操作数时按照普通符号处理，比如 -1 中的负号
+	prev := -1
+	for i, item := range items {
+		if 0 > item.class {
+			continue
+		}
+		if mathBin == item.class {
+			next := -1
+			for _, following := range items[i+1:] {
+				if 0 <= following.class {
+					next = following.class
+					break
+				}
+			}
+			switch prev {
+			case -1, mathBin, mathOp, mathRel, mathOpen, mathPunct:
+				item.class = mathOrd
+			}
+			switch next {
+			case -1, mathRel, mathClose, mathPunct:
+				item.class = mathOrd
+			}
+		}
+		prev = item.class
+	}
+
+	ret := &mathBox{class: mathOrd}
+	x := 0.0
+	prev = -1
+	for _, item := range items {
+		if 0 <= item.class {
+			if 0 <= prev {
+				space := mathSpacing[prev][item.class]
+				if 0 > space {
+					space = -space
+					if 0 < style.level {
+						space = 0
+					}
+				}
+				x += float64(space) / 18 * style.size()
+			}
+			prev = item.class
+		}
+		item.x = x
+		x += item.width
+		ret.children = append(ret.children, item)
+	}
+	ret.width = x
+	ret.fit()
+	return ret
+}
+
+// fit 根据子盒子计算盒子的高度和深度。
+func (b *mathBox) fit() {
+	for _, child := range b.children {
+		b.height = math.Max(b.height, child.height-child.y)
+		b.depth = math.Max(b.depth, child.depth+child.y)
+	}
+}
+
+// layoutMathNode 排版单个节点及其上下标。
+func (r *PdfRenderer) layoutMathNode(n *mathNode, style mathStyle) *mathBox {
+	s := style.size()
+	var ret *mathBox
+	switch n.kind {
+	case mathNodeSymbol:
+		ret = r.mathText(n.text, n.family, s)
+	case mathNodeText:
+		if "" != n.ref {
+			ret = r.layoutMathRef(n, s)
+			break
+		}
+		ret = r.mathText(n.text, n.family, s)
+		if n.error {
+			ret.color = &RGB{204, 0, 0}
+		}
+	case mathNodeGroup:
+		ret = r.layoutMathList(n.body, style)
+	case mathNodeFrac:
+		ret = r.layoutMathFrac(n, style)
+	case mathNodeSqrt:
+		ret = r.layoutMathSqrt(n, style)
+	case mathNodeOp:
+		ret = r.layoutMathOp(n, style)
+	case mathNodeSpace:
+		ret = &mathBox{width: n.size * s, class: -1}
+		return ret
+	case mathNodeLeftRight:
+		inner := r.layoutMathList(n.body, style)
+		ret = r.mathFence(inner, n.left, n.right, style)
+	case mathNodeDelim:
+		ret = mathDelimiter(n.text, n.size*s, style)
+	case mathNodeMatrix:
+		ret = r.layoutMathMatrix(n, style)
+	case mathNodeAccent:
+		ret = r.layoutMathAccent(n, style)
+	default:
+		ret = &mathBox{}
+	}
+	ret.class = n.class
+
+	if n.hasSup || n.hasSub {
+		limits := mathNodeOp == n.kind && (1 == n.limits || 0 == n.limits && style.display)
+		ret = r.layoutMathScripts(n, ret, style, limits)
+	}
+	return ret
+}
+
+// mathText 创建文本盒子，高度和深度根据字符估算。
+func (r *PdfRenderer) mathText(text, family string, size float64) *mathBox {
+	r.pdf.SetFont(family, mathFontStyle(family), size)
+	width, _ := r.pdf.MeasureTextWidth(text)
+	ret := &mathBox{text: text, family: family, size: size, width: width}
+	for _, c := range text {
+		height, depth := mathCharMetrics(c)
+		ret.height = math.Max(ret.height, height*size)
+		ret.depth = math.Max(ret.depth, depth*size)
+	}
+	return ret
+}
+
+// mathCharMetrics 估算字符 c 相对字号的高度和深度。
+func mathCharMetrics(c rune) (height, depth float64) {
+	switch {
+	case strings.ContainsRune("gpqyγημρφχψϕςβζξ", c):
+		return 0.45, 0.2
+	case strings.ContainsRune("acemnorsuvwxzαειικνοπστυω", c):
+		return 0.45, 0
+	case strings.ContainsRune("fj()[]{}|‖/⌊⌋⌈⌉⟨⟩∫∑∏", c):
+		return 0.75, 0.25
+	case strings.ContainsRune("+−=<>×÷±∓≤≥≠≈≡∼·∗", c):
+		return 0.58, 0.08
+	}
+	return 0.7, 0
+}
+
+// layoutMathScripts 排版节点 n 的上下标，limits 为 true 时上下标位于 base 的正上方和正下方。
+func (r *PdfRenderer) layoutMathScripts(n *mathNode, base *mathBox, style mathStyle, limits bool) *mathBox {
+	s := style.size()
+	scriptStyle := style.script()
+	var sup, sub *mathBox
+	if n.hasSup {
+		sup = r.layoutMathList(n.sup, scriptStyle)
+	}
+	if n.hasSub {
+		sub = r.layoutMathList(n.sub, scriptStyle)
+	}
+
+	ret := &mathBox{class: base.class}
+	ret.children = append(ret.children, base)
+	if limits {
+		gap := 0.15 * s
+		ret.width = base.width
+		if nil != sup {
+			ret.width = math.Max(ret.width, sup.width)
+		}
+		if nil != sub {
+			ret.width = math.Max(ret.width, sub.width)
+		}
+		base.x = (ret.width - base.width) / 2
+		if nil != sup {
+			sup.x = (ret.width - sup.width) / 2
+			sup.y = base.y - base.height - gap - sup.depth
+			ret.children = append(ret.children, sup)
+		}
+		if nil != sub {
+			sub.x = (ret.width - sub.width) / 2
+			sub.y = base.y + base.depth + gap + sub.height
+			ret.children = append(ret.children, sub)
+		}
+		ret.fit()
+		return ret
+	}
+
+	// 单个字符的上下标位置只取决于字号，其他情况根据 base 的高度和深度调整
+	var u, v float64
+	if "" == base.text {
+		u = base.height - base.y - 0.25*scriptStyle.size()
+		v = base.depth + base.y + 0.05*scriptStyle.size()
+	}
+	minSup := 0.36 * s
+	if style.display {
+		minSup = 0.41 * s
+	}
+	if nil != sup {
+		u = math.Max(u, math.Max(minSup, sup.depth+0.11*s))
+	}
+	if nil != sub {
+		if nil == sup {
+			v = math.Max(v, math.Max(0.15*s, sub.height-0.36*s))
+		} else {
+			v = math.Max(v, 0.25*s)
+			if gap := (u - sup.depth) - (sub.height - v); gap < 0.2*s {
+				v += 0.2*s - gap
+			}
+		}
+	}
+
+	scriptX := base.width
+	width := 0.0
+	if nil != sup {
+		sup.y = -u
+		sup.x = scriptX
+		if "italic" == base.family {
+			sup.x += 0.05 * s
+		}
+		width = math.Max(width, sup.x-scriptX+sup.width)
+		ret.children = append(ret.children, sup)
+	}
+	if nil != sub {
+		sub.y = v
+		sub.x = scriptX
+		width = math.Max(width, sub.width)
+		ret.children = append(ret.children, sub)
+	}
+	ret.width = base.width + width + 0.05*s
+	ret.fit()
+	return ret
+}
+
+// layoutMathFrac 排版分数和二项式系数。
+func (r *PdfRenderer) layoutMathFrac(n *mathNode, style mathStyle) *mathBox {
+	switch n.left {
+	case "display":
+		style = mathStyle{base: style.base, level: style.level, display: true}
+	case "text":
+		style = mathStyle{base: style.base, level: style.level}
+	}
+	s := style.size()
+	num := r.layoutMathList(n.body, style.fraction())
+	den := r.layoutMathList(n.body2, style.fraction())
+
+	axis, rule := mathAxis(s), mathRule(s)
+	gap, numShift, denShift := 0.1*s, 0.39*s, 0.35*s
+	if style.display {
+		gap, numShift, denShift = 0.2*s, 0.68*s, 0.69*s
+	}
+	ret := &mathBox{}
+	ret.width = math.Max(num.width, den.width) + 0.2*s
+	num.x = (ret.width - num.width) / 2
+	num.y = -math.Max(axis+rule/2+gap+num.depth, numShift)
+	den.x = (ret.width - den.width) / 2
+	den.y = math.Max(-axis+rule/2+gap+den.height, denShift)
+	ret.children = append(ret.children, num, den)
+	if "binom" == n.text {
+		ret.fit()
+		return r.mathFence(ret, "(", ")", style)
+	}
+
+	ret.paths = append(ret.paths, &mathPath{points: []gopdf.Point{{X: 0.05 * s, Y: -axis}, {X: ret.width - 0.05*s, Y: -axis}}, width: rule})
+	ret.fit()
+	ret.height = math.Max(ret.height, axis+rule/2)
+	return ret
+}
+
+// layoutMathSqrt 排版根式，根号使用矢量线条绘制。
+func (r *PdfRenderer) layoutMathSqrt(n *mathNode, style mathStyle) *mathBox {
+	s := style.size()
+	body := r.layoutMathList(n.body, style)
+	rule := mathRule(s)
+	gap := 0.12 * s
+	if style.display {
+		gap = 0.2 * s
+	}
+
+	top := -math.Max(body.height, 0.45*s) - gap - rule/2
+	bottom := math.Max(body.depth, 0.05*s) + 0.05*s
+	mid := bottom - (bottom-top)*0.4
+	signWidth := 0.45*s + (bottom-top)*0.08
+
+	ret := &mathBox{}
+	offset := 0.0
+	if 0 < len(n.body2) {
+		index := r.layoutMathList(n.body2, style.script().script())
+		offset = math.Max(0, index.width-0.25*s)
+		index.x = math.Max(0, 0.25*s-index.width)
+		index.y = mid - 0.08*s - index.depth
+		ret.children = append(ret.children, index)
+	}
+
+	ret.paths = append(ret.paths, &mathPath{points: []gopdf.Point{
+		{X: offset, Y: mid + 0.06*s},
+		{X: offset + 0.1*s, Y: mid},
+		{X: offset + 0.28*s, Y: bottom},
+		{X: offset + signWidth, Y: top},
+		{X: offset + signWidth + body.width + 0.1*s, Y: top},
+	}, width: rule})
+	body.x = offset + signWidth + 0.05*s
+	ret.children = append(ret.children, body)
+	ret.width = offset + signWidth + body.width + 0.15*s
+	ret.fit()
+	ret.height = math.Max(ret.height, -top+rule/2)
+	ret.depth = math.Max(ret.depth, bottom)
+	return ret
+}
+
+// layoutMathOp 排版大型运算符和函数名，大型运算符在行间公式中放大并垂直居中于数学轴。
+func (r *PdfRenderer) layoutMathOp(n *mathNode, style mathStyle) *mathBox {
+	s := style.size()
+	if 1 < utf8.RuneCountInString(n.text) {
+		return r.mathText(n.text, "regular", s)
+	}
+
+	scale := 1.15
+	if style.display {
+		scale = 1.6
+		if strings.ContainsAny(n.text, "∫∬∭∮") {
+			scale = 2
+		}
+	}
+	if 0 < style.level {
+		scale = 1
+	}
+	glyph := r.mathText(n.text, "regular", s*scale)
+	center := (glyph.height - glyph.depth) / 2
+	glyph.y = center - mathAxis(s)
+
+	ret := &mathBox{width: glyph.width + 0.05*s, family: "regular"}
+	ret.children = append(ret.children, glyph)
+	ret.fit()
+	return ret
+}
+
+// mathFence 在盒子 inner 两侧添加高度自适应的定界符 left 和 right。
+func (r *PdfRenderer) mathFence(inner *mathBox, left, right string, style mathStyle) *mathBox {
+	s := style.size()
+	axis := mathAxis(s)
+	height := 2 * math.Max(inner.height-axis, inner.depth+axis)
+	var l, rt *mathBox
+	if height <= 1.2*s && "." != left && "." != right && !strings.ContainsAny(left+right, "‖") {
+		l, rt = r.mathText(left, "regular", s), r.mathText(right, "regular", s)
+	} else {
+		height = math.Max(height*1.05, 1.2*s)
+		l, rt = mathDelimiter(left, height, style), mathDelimiter(right, height, style)
+	}
+	if "." == left {
+		l = &mathBox{width: 0.12 * s}
+	}
+	if "." == right {
+		rt = &mathBox{width: 0.12 * s}
+	}
+
+	ret := &mathBox{class: mathInner}
+	l.x = 0
+	inner.x = l.width
+	rt.x = l.width + inner.width
+	ret.width = rt.x + rt.width
+	ret.children = append(ret.children, l, inner, rt)
+	ret.fit()
+	return ret
+}
+
+// mathDelimiter 使用矢量线条绘制高度为 height、垂直居中于数学轴的定界符 delim。
+func mathDelimiter(delim string, height float64, style mathStyle) *mathBox {
+	s := style.size()
+	axis := mathAxis(s)
+	top, bottom := -axis-height/2, -axis+height/2
+	mid := -axis
+	w := math.Min(0.3*s+height*0.05, 0.6*s)
+	lineWidth := math.Max(0.06*s, 0.6)
+	ret := &mathBox{width: w, height: -top, depth: bottom, class: mathOrd}
+	lines := func(points ...gopdf.Point) {
+		ret.paths = append(ret.paths, &mathPath{points: points, width: lineWidth})
+	}
+	curves := func(points ...gopdf.Point) {
+		ret.paths = append(ret.paths, &mathPath{points: points, width: lineWidth, curve: true})
+	}
+
+	switch delim {
+	case "(":
+		curves(gopdf.Point{X: 0.8 * w, Y: top}, gopdf.Point{X: 0.05 * w, Y: top + height*0.25},
+			gopdf.Point{X: 0.05 * w, Y: bottom - height*0.25}, gopdf.Point{X: 0.8 * w, Y: bottom})
+	case ")":
+		curves(gopdf.Point{X: 0.2 * w, Y: top}, gopdf.Point{X: 0.95 * w, Y: top + height*0.25},
+			gopdf.Point{X: 0.95 * w, Y: bottom - height*0.25}, gopdf.Point{X: 0.2 * w, Y: bottom})
+	case "[":
+		lines(gopdf.Point{X: 0.8 * w, Y: top}, gopdf.Point{X: 0.3 * w, Y: top}, gopdf.Point{X: 0.3 * w, Y: bottom}, gopdf.Point{X: 0.8 * w, Y: bottom})
+	case "]":
+		lines(gopdf.Point{X: 0.2 * w, Y: top}, gopdf.Point{X: 0.7 * w, Y: top}, gopdf.Point{X: 0.7 * w, Y: bottom}, gopdf.Point{X: 0.2 * w, Y: bottom})
+	case "⌊":
+		lines(gopdf.Point{X: 0.3 * w, Y: top}, gopdf.Point{X: 0.3 * w, Y: bottom}, gopdf.Point{X: 0.8 * w, Y: bottom})
+	case "⌋":
+		lines(gopdf.Point{X: 0.7 * w, Y: top}, gopdf.Point{X: 0.7 * w, Y: bottom}, gopdf.Point{X: 0.2 * w, Y: bottom})
+	case "⌈":
+		lines(gopdf.Point{X: 0.8 * w, Y: top}, gopdf.Point{X: 0.3 * w, Y: top}, gopdf.Point{X: 0.3 * w, Y: bottom})
+	case "⌉":
+		lines(gopdf.Point{X: 0.2 * w, Y: top}, gopdf.Point{X: 0.7 * w, Y: top}, gopdf.Point{X: 0.7 * w, Y: bottom})
+	case "{":
+		curves(gopdf.Point{X: 0.85 * w, Y: top}, gopdf.Point{X: 0.3 * w, Y: top}, gopdf.Point{X: 0.6 * w, Y: mid}, gopdf.Point{X: 0.1 * w, Y: mid},
+			gopdf.Point{X: 0.1 * w, Y: mid}, gopdf.Point{X: 0.6 * w, Y: mid}, gopdf.Point{X: 0.3 * w, Y: bottom}, gopdf.Point{X: 0.85 * w, Y: bottom})
+	case "}":
+		curves(gopdf.Point{X: 0.15 * w, Y: top}, gopdf.Point{X: 0.7 * w, Y: top}, gopdf.Point{X: 0.4 * w, Y: mid}, gopdf.Point{X: 0.9 * w, Y: mid},
+			gopdf.Point{X: 0.9 * w, Y: mid}, gopdf.Point{X: 0.4 * w, Y: mid}, gopdf.Point{X: 0.7 * w, Y: bottom}, gopdf.Point{X: 0.15 * w, Y: bottom})
+	case "⟨":
+		lines(gopdf.Point{X: 0.8 * w, Y: top}, gopdf.Point{X: 0.2 * w, Y: mid}, gopdf.Point{X: 0.8 * w, Y: bottom})
+	case "⟩":
+		lines(gopdf.Point{X: 0.2 * w, Y: top}, gopdf.Point{X: 0.8 * w, Y: mid}, gopdf.Point{X: 0.2 * w, Y: bottom})
+	case "|":
+		ret.width = 0.3 * s
+		lines(gopdf.Point{X: 0.15 * s, Y: top}, gopdf.Point{X: 0.15 * s, Y: bottom})
+	case "‖":
+		ret.width = 0.4 * s
+		lines(gopdf.Point{X: 0.12 * s, Y: top}, gopdf.Point{X: 0.12 * s, Y: bottom})
+		lines(gopdf.Point{X: 0.28 * s, Y: top}, gopdf.Point{X: 0.28 * s, Y: bottom})
+	case "/":
+		lines(gopdf.Point{X: 0.9 * w, Y: top}, gopdf.Point{X: 0.1 * w, Y: bottom})
+	case "\\":
+		lines(gopdf.Point{X: 0.1 * w, Y: top}, gopdf.Point{X: 0.9 * w, Y: bottom})
+	default:
+		ret.width = 0.12 * s
+	}
+	return ret
+}
+
+// layoutMathMatrix 排版矩阵、cases、aligned 等环境。
+func (r *PdfRenderer) layoutMathMatrix(n *mathNode, style mathStyle) *mathBox {
+	s := style.size()
+	cellStyle := style
+	colGap := s
+	rowGap := 0.2 * s
+	aligned := "aligned" == n.text || "gathered" == n.text
+	if aligned {
+		rowGap = 0.35 * s
+	} else {
+		cellStyle.display = false
+	}
+	aligns := n.aligns
+	if "" == aligns {
+		aligns = "c"
+	}
+
+	var cells [][]*mathBox
+	var colWidths, rowHeights, rowDepths []float64
+	for _, row := range n.rows {
+		var boxes []*mathBox
+		height, depth := 0.7*s, 0.3*s
+		for j, cell := range row {
+			if aligned && 1 == j%2 {
+				// aligned 环境右侧列的关系符前需要保留间距
+				cell = append([]*mathNode{{kind: mathNodeGroup, class: mathOrd}}, cell...)
+			}
+			box := r.layoutMathList(cell, cellStyle)
+			boxes = append(boxes, box)
+			if j >= len(colWidths) {
+				colWidths = append(colWidths, 0)
+			}
+			colWidths[j] = math.Max(colWidths[j], box.width)
+			height = math.Max(height, box.height)
+			depth = math.Max(depth, box.depth)
+		}
+		cells = append(cells, boxes)
+		rowHeights = append(rowHeights, height)
+		rowDepths = append(rowDepths, depth)
+	}
+
+	var colX []float64
+	width := 0.0
+	for j, colWidth := range colWidths {
+		if 0 < j {
+			if !aligned || 0 == j%2 {
+				width += colGap
+				if aligned {
+					width += colGap
+				}
+			}
+		}
+		colX = append(colX, width)
+		width += colWidth
+	}
+	total := 0.0
+	for i := range rowHeights {
+		total += rowHeights[i] + rowDepths[i]
+		if 0 < i {
+			total += rowGap
+		}
+	}
+
+	ret := &mathBox{width: width}
+	y := -mathAxis(s) - total/2
+	for i, boxes := range cells {
+		y += rowHeights[i]
+		for j, box := range boxes {
+			box.x = colX[j]
+			switch aligns[j%len(aligns)] {
+			case 'r':
+				box.x += colWidths[j] - box.width
+			case 'c':
+				box.x += (colWidths[j] - box.width) / 2
+			}
+			box.y = y
+			ret.children = append(ret.children, box)
+		}
+		y += rowDepths[i] + rowGap
+	}
+	ret.fit()
+	ret.height = math.Max(ret.height, mathAxis(s)+total/2)
+	ret.depth = math.Max(ret.depth, total/2-mathAxis(s))
+	if "" == n.left && "" == n.right {
+		return ret
+	}
+
+	left, right := n.left, n.right
+	if "" == left {
+		left = "."
+	}
+	if "" == right {
+		right = "."
+	}
+	pad := &mathBox{width: ret.width + 0.2*s}
+	ret.x = 0.1 * s
+	pad.children = append(pad.children, ret)
+	pad.fit()
+	fence := r.mathFence(pad, left, right, style)
+	return fence
+}
+
+// layoutMathAccent 排版重音、上划线和下划线。
+func (r *PdfRenderer) layoutMathAccent(n *mathNode, style mathStyle) *mathBox {
+	s := style.size()
+	body := r.layoutMathList(n.body, style)
+	rule := mathRule(s)
+	ret := &mathBox{width: body.width}
+	ret.children = append(ret.children, body)
+
+	cx := body.width / 2
+	if 1 == len(n.body) && "italic" == n.body[0].family {
+		cx += 0.05 * s
+	}
+	w := math.Max(0.3*s, math.Min(body.width*0.8, 0.5*s))
+	if n.wide || 1 < len(n.body) {
+		w = body.width
+	}
+	top := -body.height - 0.08*s
+	lines := func(points ...gopdf.Point) {
+		ret.paths = append(ret.paths, &mathPath{points: points, width: rule})
+	}
+	dot := func(x float64) {
+		ret.paths = append(ret.paths, &mathPath{points: polygonPoints(x, top-0.06*s, 0.05*s, 12, 0), fill: true})
+	}
+
+	switch n.text {
+	case "hat":
+		lines(gopdf.Point{X: cx - w/2, Y: top}, gopdf.Point{X: cx, Y: top - 0.12*s}, gopdf.Point{X: cx + w/2, Y: top})
+	case "check":
+		lines(gopdf.Point{X: cx - w/2, Y: top - 0.12*s}, gopdf.Point{X: cx, Y: top}, gopdf.Point{X: cx + w/2, Y: top - 0.12*s})
+	case "bar":
+		x0, x1 := cx-w/2, cx+w/2
+		if n.wide || 1 < len(n.body) || body.width > 0.6*s {
+			x0, x1 = 0, body.width
+		}
+		lines(gopdf.Point{X: x0, Y: top - 0.04*s}, gopdf.Point{X: x1, Y: top - 0.04*s})
+	case "vec":
+		y := top - 0.08*s
+		lines(gopdf.Point{X: cx - w/2, Y: y}, gopdf.Point{X: cx + w/2, Y: y})
+		lines(gopdf.Point{X: cx + w/2 - 0.1*s, Y: y - 0.07*s}, gopdf.Point{X: cx + w/2, Y: y}, gopdf.Point{X: cx + w/2 - 0.1*s, Y: y + 0.07*s})
+	case "dot":
+		dot(cx)
+	case "ddot":
+		dot(cx - 0.1*s)
+		dot(cx + 0.1*s)
+	case "tilde":
+		y := top - 0.07*s
+		ret.paths = append(ret.paths, &mathPath{points: []gopdf.Point{
+			{X: cx - w/2, Y: y + 0.03*s}, {X: cx - w/4, Y: y - 0.1*s}, {X: cx + w/4, Y: y + 0.1*s}, {X: cx + w/2, Y: y - 0.03*s},
+		}, width: rule, curve: true})
+	case "acute":
+		lines(gopdf.Point{X: cx - 0.05*s, Y: top}, gopdf.Point{X: cx + 0.1*s, Y: top - 0.14*s})
+	case "grave":
+		lines(gopdf.Point{X: cx + 0.05*s, Y: top}, gopdf.Point{X: cx - 0.1*s, Y: top - 0.14*s})
+	case "underline":
+		y := body.depth + 0.1*s
+		lines(gopdf.Point{X: 0, Y: y}, gopdf.Point{X: body.width, Y: y})
+		ret.fit()
+		ret.depth = y + rule
+		return ret
+	}
+	ret.fit()
+	ret.height = body.height + 0.22*s
+	return ret
+}
+
+// mathAxis 返回字号 size 下数学轴（分数线、运算符中心）距基线的高度。
+func mathAxis(size float64) float64 {
+	return 0.25 * size
+}
+
+// mathRule 返回字号 size 下分数线、根号等线条的粗细。
+func mathRule(size float64) float64 {
+	return math.Max(0.05*size, 0.5)
+}
@@ -0,0 +1,701 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 公式节点类型
+const (
+	mathNodeSymbol    = iota // 符号，比如字母、数字、运算符
+	mathNodeText             // \text{} 等文本
+	mathNodeGroup            // {} 分组
+	mathNodeFrac             // \frac{}{}、\binom{}{}
+	mathNodeSqrt             // \sqrt[]{}
+	mathNodeOp               // 大型运算符和 \sin 等函数名
+	mathNodeSpace            // 间距
+	mathNodeLeftRight        // \left \right
+	mathNodeDelim            // \big( 等固定大小的定界符
+	mathNodeMatrix           // 矩阵、cases、aligned 等环境
+	mathNodeAccent           // \hat{} 等重音
+	mathNodeStyle            // \displaystyle 等样式切换
+)
+
+// 原子类别，决定原子之间的间距
+const (
+	mathOrd = iota
+	mathOp
+	mathBin
+	mathRel
+	mathOpen
+	mathClose
+	mathPunct
+	mathInner
+)
+
+// mathNode 描述了 TeX 公式解析后的节点。
+type mathNode struct {
+	kind   int         // 节点类型
+	class  int         // 原子类别
+	text   string      // 符号、文本、定界符、重音或者环境名
+	family string      // 字体：regular、bold、italic
+	letter bool        // 是否是字母，字体命令只作用于字母
+	error  bool        // 是否是无法识别的命令
+	limits int         // 上下标位置：0 默认，1 \limits，-1 \nolimits
+	wide   bool        // 重音是否横跨整个内容，比如 \widehat{}
+	size   float64     // 间距（em）或者定界符大小（倍数）
+	body   []*mathNode // 分组内容、分子、根式内容、重音内容
+	body2  []*mathNode // 分母、根式次数
+	sup    []*mathNode // 上标
+	sub    []*mathNode // 下标
+	hasSup bool
+	hasSub bool
+	left   string          // \left 定界符或者环境左侧定界符
+	right  string          // \right 定界符或者环境右侧定界符
+	rows   [][][]*mathNode // 环境的行、列
+	aligns string          // 环境各列的对齐方式：l、c、r
+	ref    string          // \eqref{}、\ref{} 引用的公式标签
+}
+
+// mathEquation 描述了行间公式的编号信息。
+type mathEquation struct {
+	number string // 编号，\tag{} 指定或者自动生成
+	star   bool   // 编号是否不加括号，\tag*{} 指定
+	label  string // 标签，\label{} 指定
+	notag  bool   // 是否禁用编号，\nonumber、\notag 或者带 * 的环境
+}
+
+// tag 返回公式编号的显示文本。
+func (eq *mathEquation) tag() string {
+	if "" == eq.number || eq.star {
+		return eq.number
+	}
+	return "(" + eq.number + ")"
+}
+
+// mathParser 描述了 TeX 公式解析器。
+type mathParser struct {
+	src  []rune
+	pos  int
+	stop int           // parseList 最后遇到的结束标记的起始位置
+	eq   *mathEquation // 解析过程中收集的编号信息
+}
+
+// parseMath 解析 TeX 公式 tex，公式中包含 \\ 或者 & 时按照 aligned 环境处理。
+func parseMath(tex string) ([]*mathNode, *mathEquation) {
+	p := &mathParser{src: []rune(tex), eq: &mathEquation{}}
+	rows := p.parseRows(false)
+	if 1 == len(rows) && 1 == len(rows[0]) {
+		return rows[0][0], p.eq
+	}
+
+	env := &mathNode{kind: mathNodeMatrix, text: "gathered", aligns: "c"}
+	for _, row := range rows {
+		if 1 < len(row) {
+			env.text, env.aligns = "aligned", "rl"
+			break
+		}
+	}
+	env.rows = rows
+	return []*mathNode{env}, p.eq
+}
+
+// parseRows 解析以 & 分列、以 \\ 分行的内容，env 为 true 时直到遇到 \end{env}，否则直到结束。
+//
+// 没有匹配的 }、\right 以及顶层的 \end 按照无法识别的命令输出，然后继续解析后面的内容，避免丢失公式的剩余部分。
+func (p *mathParser) parseRows(env bool) (rows [][][]*mathNode) {
+	var row [][]*mathNode
+	var cell []*mathNode
+	for {
+		list, stop := p.parseList()
+		cell = append(cell, list...)
+		switch {
+		case "}" == stop, `\right` == stop, `\end` == stop && !env:
+			if `\end` == stop {
+				stop += "{" + p.parseRawArg() + "}"
+			}
+			cell = append(cell, &mathNode{kind: mathNodeText, class: mathOrd, text: stop, family: "regular", error: true})
+			continue
+		}
+		row = append(row, cell)
+		cell = nil
+		if "&" == stop {
+			continue
+		}
+		rows = append(rows, row)
+		row = nil
+		if `\\` == stop {
+			continue
+		}
+		if `\end` == stop {
+			p.parseRawArg() // 环境名
+		}
+		break
+	}
+	// 去掉最后一个 \\ 产生的空行
+	if last := rows[len(rows)-1]; 1 < len(rows) && 1 == len(last) && 1 > len(last[0]) {
+		rows = rows[:len(rows)-1]
+	}
+	return
+}
+
+// parseList 解析节点列表，直到遇到 }、&、\\、\right、\end 或者结束，返回值 stop 为遇到的结束标记。
+func (p *mathParser) parseList() (ret []*mathNode, stop string) {
+	for {
+		p.skipSpace()
+		p.stop = p.pos
+		if p.pos >= len(p.src) {
+			return
+		}
+
+		c := p.src[p.pos]
+		switch c {
+		case '}':
+			p.pos++
+			return ret, "}"
+		case '&':
+			p.pos++
+			return ret, "&"
+		case '^', '_':
+			p.pos++
+			arg := p.parseArg()
+			base := lastScriptBase(&ret)
+			if '^' == c {
+				base.sup, base.hasSup = append(base.sup, arg...), true
+			} else {
+				base.sub, base.hasSub = append(base.sub, arg...), true
+			}
+			continue
+		case '\'':
+			p.pos++
+			base := lastScriptBase(&ret)
+			base.sup, base.hasSup = append(base.sup, &mathNode{kind: mathNodeSymbol, class: mathOrd, text: "′", family: "regular"}), true
+			continue
+		case '{':
+			p.pos++
+			body, _ := p.parseList()
+			ret = append(ret, &mathNode{kind: mathNodeGroup, class: mathOrd, body: body})
+			continue
+		case '\\':
+			cmd := p.parseCommand()
+			switch cmd {
+			case `\\`, `\cr`:
+				return ret, `\\`
+			case `\right`, `\end`:
+				return ret, cmd
+			}
+			ret = p.parseCommandNode(cmd, ret)
+			continue
+		}
+
+		p.pos++
+		ret = append(ret, symbolNode(c))
+	}
+}
+
+// parseCommandNode 解析命令 cmd 并将生成的节点追加到 list 中。
+func (p *mathParser) parseCommandNode(cmd string, list []*mathNode) []*mathNode {
+	name := cmd[1:]
+	if symbol, ok := mathSymbols[name]; ok {
+		return append(list, &mathNode{kind: mathNodeSymbol, class: symbol.class, text: symbol.text, family: "regular"})
+	}
+	if op, ok := mathLargeOps[name]; ok {
+		return append(list, &mathNode{kind: mathNodeOp, class: mathOp, text: op, family: "regular"})
+	}
+	if limits, ok := mathFunctions[name]; ok {
+		node := &mathNode{kind: mathNodeOp, class: mathOp, text: name, family: "regular", limits: -1}
+		if limits {
+			node.limits = 0
+		}
+		return append(list, node)
+	}
+	if space, ok := mathSpaces[name]; ok {
+		return append(list, &mathNode{kind: mathNodeSpace, size: space})
+	}
+	if accent, ok := mathAccents[name]; ok {
+		return append(list, &mathNode{kind: mathNodeAccent, class: mathOrd, text: accent, wide: mathWideAccents[name], body: p.parseArg()})
+	}
+	if size, ok := mathDelimSizes[strings.TrimRight(name, "lrm")]; ok {
+		delim := p.parseDelimiter()
+		class := mathOrd
+		switch {
+		case strings.HasSuffix(name, "l"):
+			class = mathOpen
+		case strings.HasSuffix(name, "r"):
+			class = mathClose
+		case strings.ContainsAny(delim, "([{⟨⌊⌈"):
+			class = mathOpen
+		case strings.ContainsAny(delim, ")]}⟩⌋⌉"):
+			class = mathClose
+		}
+		return append(list, &mathNode{kind: mathNodeDelim, class: class, text: delim, size: size})
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		node := &mathNode{kind: mathNodeFrac, class: mathInner, text: "frac", body: p.parseArg(), body2: p.parseArg()}
+		if "dfrac" == name || "cfrac" == name {
+			node.left = "display"
+		} else if "tfrac" == name {
+			node.left = "text"
+		}
+		return append(list, node)
+	case "binom", "dbinom", "tbinom":
+		return append(list, &mathNode{kind: mathNodeFrac, class: mathInner, text: "binom", body: p.parseArg(), body2: p.parseArg()})
+	case "sqrt":
+		node := &mathNode{kind: mathNodeSqrt, class: mathOrd}
+		if p.peek('[') {
+			p.pos++
+			node.body2 = p.parseUntil(']')
+		}
+		node.body = p.parseArg()
+		return append(list, node)
+	case "text", "textrm", "textnormal", "mbox", "hbox", "textup":
+		return append(list, &mathNode{kind: mathNodeText, class: mathOrd, text: p.parseRawArg(), family: "regular"})
+	case "textbf":
+		return append(list, &mathNode{kind: mathNodeText, class: mathOrd, text: p.parseRawArg(), family: "bold"})
+	case "textit", "emph":
+		return append(list, &mathNode{kind: mathNodeText, class: mathOrd, text: p.parseRawArg(), family: "italic"})
+	case "mathrm", "mathsf", "mathtt", "rm":
+		return append(list, &mathNode{kind: mathNodeGroup, class: mathOrd, body: setMathFamily(p.parseArg(), "regular")})
+	case "mathbf", "boldsymbol", "bm", "bf":
+		return append(list, &mathNode{kind: mathNodeGroup, class: mathOrd, body: setMathFamily(p.parseArg(), "bold")})
+	case "mathit", "mathcal", "mathscr", "mathfrak", "it":
+		return append(list, &mathNode{kind: mathNodeGroup, class: mathOrd, body: setMathFamily(p.parseArg(), "italic")})
+	case "mathbb":
+		body := p.parseArg()
+		for _, n := range body {
+			if doubleStruck, ok := mathDoubleStruck[n.text]; ok && n.letter {
+				n.text, n.family, n.letter = doubleStruck, "regular", false
+			}
+		}
+		return append(list, &mathNode{kind: mathNodeGroup, class: mathOrd, body: setMathFamily(body, "bold")})
+	case "operatorname", "operatorname*":
+		node := &mathNode{kind: mathNodeOp, class: mathOp, text: p.parseRawArg(), family: "regular", limits: -1}
+		if p.peek('*') || "operatorname*" == name {
+			node.limits = 0
+		}
+		return append(list, node)
+	case "limits", "nolimits":
+		if 0 < len(list) && mathNodeOp == list[len(list)-1].kind {
+			list[len(list)-1].limits = 1
+			if "nolimits" == name {
+				list[len(list)-1].limits = -1
+			}
+		}
+		return list
+	case "left":
+		node := &mathNode{kind: mathNodeLeftRight, class: mathInner, left: p.parseDelimiter()}
+		var stop string
+		node.body, stop = p.parseList()
+		if `\right` == stop {
+			node.right = p.parseDelimiter()
+		} else {
+			// 缺少 \right 时右侧使用空定界符，遇到的 }、& 等结束标记交给外层处理
+			node.right = "."
+			p.unread()
+		}
+		return append(list, node)
+	case "begin":
+		return append(list, p.parseEnvironment(strings.TrimSpace(p.parseRawArg())))
+	case "displaystyle", "textstyle", "scriptstyle", "scriptscriptstyle":
+		return append(list, &mathNode{kind: mathNodeStyle, text: strings.TrimSuffix(name, "style")})
+	case "hspace", "hspace*", "color":
+		// 暂不支持，忽略参数
+		p.parseRawArg()
+		return list
+	case "tag", "tag*":
+		p.eq.number, p.eq.star = strings.TrimSpace(p.parseRawArg()), "tag*" == name
+		return list
+	case "label":
+		p.eq.label = strings.TrimSpace(p.parseRawArg())
+		return list
+	case "nonumber", "notag":
+		p.eq.notag = true
+		return list
+	case "eqref", "ref":
+		return append(list, &mathNode{kind: mathNodeText, class: mathOrd, text: name, family: "regular", ref: strings.TrimSpace(p.parseRawArg())})
+	case "not":
+		// \not 只支持 \not= 等常见组合，其他情况忽略
+		p.skipSpace()
+		if p.peek('=') {
+			p.pos++
+			return append(list, &mathNode{kind: mathNodeSymbol, class: mathRel, text: "≠", family: "regular"})
+		}
+		return list
+	}
+
+	if 2 == len(cmd) && !unicode.IsLetter(rune(cmd[1])) {
+		// \{ \} \# 等转义符号
+		node := symbolNode(rune(cmd[1]))
+		if '{' == cmd[1] {
+			node.class = mathOpen
+		} else if '}' == cmd[1] {
+			node.class = mathClose
+		}
+		return append(list, node)
+	}
+
+	return append(list, &mathNode{kind: mathNodeText, class: mathOrd, text: cmd, family: "regular", error: true})
+}
+
+// parseEnvironment 解析 \begin{env} 到 \end{env} 之间的内容。
+func (p *mathParser) parseEnvironment(env string) *mathNode {
+	node := &mathNode{kind: mathNodeMatrix, class: mathInner, text: strings.TrimSuffix(env, "*"), aligns: "c"}
+	switch node.text {
+	case "pmatrix":
+		node.left, node.right = "(", ")"
+	case "bmatrix":
+		node.left, node.right = "[", "]"
+	case "Bmatrix":
+		node.left, node.right = "{", "}"
+	case "vmatrix":
+		node.left, node.right = "|", "|"
+	case "Vmatrix":
+		node.left, node.right = "‖", "‖"
+	case "cases":
+		node.left, node.aligns = "{", "ll"
+	case "rcases":
+		node.right, node.aligns = "}", "ll"
+	case "aligned", "align", "alignat", "alignedat", "split", "eqnarray", "flalign":
+		if "alignat" == node.text || "alignedat" == node.text {
+			p.parseRawArg()
+		}
+		node.text, node.aligns = "aligned", "rl"
+	case "equation", "gather", "gathered", "displaymath", "multline":
+		node.text = "gathered"
+	case "array", "darray":
+		node.aligns = strings.NewReplacer("|", "", " ", "").Replace(p.parseRawArg())
+	}
+	if strings.HasSuffix(env, "*") {
+		p.eq.notag = true
+	}
+	node.rows = p.parseRows(true)
+	return node
+}
+
+// parseArg 解析命令的一个参数，参数可以是 {} 分组或者单个符号、命令。
+//
+// 缺少参数（遇到结束或者 }、&、\\、\right、\end 等结束标记）时返回空，结束标记留给外层处理。
+func (p *mathParser) parseArg() []*mathNode {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil
+	}
+
+	c := p.src[p.pos]
+	switch c {
+	case '{':
+		p.pos++
+		ret, _ := p.parseList()
+		return ret
+	case '}', '&':
+		return nil
+	case '\\':
+		start := p.pos
+		switch cmd := p.parseCommand(); cmd {
+		case `\\`, `\cr`, `\right`, `\end`:
+			p.pos = start
+			return nil
+		default:
+			return p.parseCommandNode(cmd, nil)
+		}
+	}
+	p.pos++
+	return []*mathNode{symbolNode(c)}
+}
+
+// parseRawArg 解析命令的一个参数并返回其原始文本，用于 \text{} 等不需要解析公式的命令。
+func (p *mathParser) parseRawArg() string {
+	p.skipSpace()
+	if p.peek('*') {
+		p.pos++
+		p.skipSpace()
+	}
+	if !p.peek('{') {
+		if p.pos >= len(p.src) {
+			return ""
+		}
+		p.pos++
+		return string(p.src[p.pos-1])
+	}
+
+	p.pos++
+	start, depth := p.pos, 1
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		if 0 == depth {
+			p.pos++
+			return string(p.src[start : p.pos-1])
+		}
+	}
+	return string(p.src[start:])
+}
+
+// parseUntil 解析节点列表直到遇到不在 {} 和 [] 中的 end，用于 \sqrt[n] 这样的可选参数，可选参数中可以嵌套 \sqrt[n]{}。
+func (p *mathParser) parseUntil(end rune) (ret []*mathNode) {
+	start := p.pos
+	depth, brackets := 0, 0
+scan:
+	for ; p.pos < len(p.src); p.pos++ {
+		c := p.src[p.pos]
+		switch {
+		case '\\' == c:
+			p.pos++ // 跳过 \{、\] 等转义字符
+		case '{' == c:
+			depth++
+		case '}' == c:
+			depth--
+		case '[' == c && 0 == depth:
+			brackets++
+		case end == c && 0 == depth && 0 < brackets:
+			brackets--
+		case end == c && 0 == depth:
+			break scan
+		}
+	}
+	if p.pos > len(p.src) { // 以 \ 结尾
+		p.pos = len(p.src)
+	}
+	sub := &mathParser{src: p.src[start:p.pos], eq: p.eq}
+	ret, _ = sub.parseList()
+	p.pos++
+	return
+}
+
+// parseDelimiter 解析 \left、\big 等命令后面的定界符，. 表示空定界符。
+func (p *mathParser) parseDelimiter() string {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return "."
+	}
+
+	c := p.src[p.pos]
+	if '\\' != c {
+		p.pos++
+		return string(c)
+	}
+	cmd := p.parseCommand()
+	if delim, ok := mathDelimiters[cmd[1:]]; ok {
+		return delim
+	}
+	if symbol, ok := mathSymbols[cmd[1:]]; ok {
+		return symbol.text
+	}
+	return "."
+}
+
+// parseCommand 解析 \ 开头的命令名，包括单个非字母字符构成的命令，比如 \, 和 \\。
+func (p *mathParser) parseCommand() string {
+	start := p.pos
+	p.pos++
+	if p.pos >= len(p.src) {
+		return `\`
+	}
+	if !isMathLetter(p.src[p.pos]) {
+		p.pos++
+		return string(p.src[start:p.pos])
+	}
+	for p.pos < len(p.src) && isMathLetter(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.peek('*') {
+		name := string(p.src[start:p.pos]) + "*"
+		switch name {
+		case `\operatorname*`, `\hspace*`, `\tag*`:
+			p.pos++
+			return name
+		}
+	}
+	return string(p.src[start:p.pos])
+}
+
+// unread 回退 parseList 最后遇到的结束标记，让外层的 parseList 重新读取。
+func (p *mathParser) unread() {
+	p.pos = p.stop
+}
+
+func (p *mathParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *mathParser) peek(c rune) bool {
+	return p.pos < len(p.src) && c == p.src[p.pos]
+}
+
+func isMathLetter(c rune) bool {
+	return 'a' <= c && 'z' >= c || 'A' <= c && 'Z' >= c
+}
+
+// lastScriptBase 返回上下标所属的节点，列表为空或者最后一个节点已经有对应上下标时追加一个空分组。
+func lastScriptBase(list *[]*mathNode) *mathNode {
+	if 0 < len(*list) {
+		last := (*list)[len(*list)-1]
+		if mathNodeSpace != last.kind && mathNodeStyle != last.kind {
+			return last
+		}
+	}
+	base := &mathNode{kind: mathNodeGroup, class: mathOrd}
+	*list = append(*list, base)
+	return base
+}
+
+// symbolNode 根据字符 c 创建符号节点并确定其原子类别和字体。
+func symbolNode(c rune) *mathNode {
+	ret := &mathNode{kind: mathNodeSymbol, class: mathOrd, text: string(c), family: "regular"}
+	switch {
+	case isMathLetter(c):
+		ret.family, ret.letter = "italic", true
+	case strings.ContainsRune("+-*±∓×÷∪∩∧∨⊕⊗", c):
+		ret.class = mathBin
+		if '-' == c {
+			ret.text = "−"
+		} else if '*' == c {
+			ret.text = "∗"
+		}
+	case strings.ContainsRune("=<>:≤≥≠≈≡∼→←↔⇒⇐⇔∈∉⊂⊃⊆⊇∝", c):
+		ret.class = mathRel
+	case strings.ContainsRune(",;", c):
+		ret.class = mathPunct
+	case strings.ContainsRune("([", c):
+		ret.class = mathOpen
+	case strings.ContainsRune(")]!?", c):
+		ret.class = mathClose
+	case '~' == c:
+		ret.kind, ret.size = mathNodeSpace, 0.25
+	}
+	return ret
+}
+
+// setMathFamily 将节点列表 nodes 中的字母设置为字体 family。
+func setMathFamily(nodes []*mathNode, family string) []*mathNode {
+	for _, n := range nodes {
+		if n.letter || mathNodeSymbol == n.kind && "bold" == family {
+			n.family = family
+		}
+		setMathFamily(n.body, family)
+		setMathFamily(n.body2, family)
+	}
+	return nodes
+}
+
+type mathSymbol struct {
+	text  string
+	class int
+}
+
+var mathSymbols = map[string]mathSymbol{
+	// 希腊字母
+	"alpha": {"α", mathOrd}, "beta": {"β", mathOrd}, "gamma": {"γ", mathOrd}, "delta": {"δ", mathOrd},
+	"epsilon": {"ϵ", mathOrd}, "varepsilon": {"ε", mathOrd}, "zeta": {"ζ", mathOrd}, "eta": {"η", mathOrd},
+	"theta": {"θ", mathOrd}, "vartheta": {"ϑ", mathOrd}, "iota": {"ι", mathOrd}, "kappa": {"κ", mathOrd},
+	"lambda": {"λ", mathOrd}, "mu": {"μ", mathOrd}, "nu": {"ν", mathOrd}, "xi": {"ξ", mathOrd},
+	"omicron": {"ο", mathOrd}, "pi": {"π", mathOrd}, "varpi": {"ϖ", mathOrd}, "rho": {"ρ", mathOrd},
+	"varrho": {"ϱ", mathOrd}, "sigma": {"σ", mathOrd}, "varsigma": {"ς", mathOrd}, "tau": {"τ", mathOrd},
+	"upsilon": {"υ", mathOrd}, "phi": {"ϕ", mathOrd}, "varphi": {"φ", mathOrd}, "chi": {"χ", mathOrd},
+	"psi": {"ψ", mathOrd}, "omega": {"ω", mathOrd},
+	"Gamma": {"Γ", mathOrd}, "Delta": {"Δ", mathOrd}, "Theta": {"Θ", mathOrd}, "Lambda": {"Λ", mathOrd},
+	"Xi": {"Ξ", mathOrd}, "Pi": {"Π", mathOrd}, "Sigma": {"Σ", mathOrd}, "Upsilon": {"Υ", mathOrd},
+	"Phi": {"Φ", mathOrd}, "Psi": {"Ψ", mathOrd}, "Omega": {"Ω", mathOrd},
+
+	// 其他符号
+	"infty": {"∞", mathOrd}, "partial": {"∂", mathOrd}, "nabla": {"∇", mathOrd}, "forall": {"∀", mathOrd},
+	"exists": {"∃", mathOrd}, "nexists": {"∄", mathOrd}, "emptyset": {"∅", mathOrd}, "varnothing": {"∅", mathOrd},
+	"angle": {"∠", mathOrd}, "triangle": {"△", mathOrd}, "prime": {"′", mathOrd}, "hbar": {"ℏ", mathOrd},
+	"ell": {"ℓ", mathOrd}, "Re": {"ℜ", mathOrd}, "Im": {"ℑ", mathOrd}, "aleph": {"ℵ", mathOrd},
+	"neg": {"¬", mathOrd}, "lnot": {"¬", mathOrd}, "top": {"⊤", mathOrd}, "bot": {"⊥", mathOrd},
+	"ldots": {"…", mathInner}, "dots": {"…", mathInner}, "cdots": {"⋯", mathInner}, "vdots": {"⋮", mathOrd},
+	"ddots": {"⋱", mathInner}, "degree": {"°", mathOrd}, "%": {"%", mathOrd}, "$": {"$", mathOrd},
+	"#": {"#", mathOrd}, "&": {"&", mathOrd}, "_": {"_", mathOrd}, "|": {"‖", mathOrd},
+	"langle": {"⟨", mathOpen}, "rangle": {"⟩", mathClose}, "lfloor": {"⌊", mathOpen}, "rfloor": {"⌋", mathClose},
+	"lceil": {"⌈", mathOpen}, "rceil": {"⌉", mathClose}, "lbrace": {"{", mathOpen}, "rbrace": {"}", mathClose},
+	"vert": {"|", mathOrd}, "Vert": {"‖", mathOrd}, "backslash": {"\\", mathOrd},
+
+	// 二元运算符
+	"pm": {"±", mathBin}, "mp": {"∓", mathBin}, "times": {"×", mathBin}, "div": {"÷", mathBin},
+	"cdot": {"·", mathBin}, "ast": {"∗", mathBin}, "star": {"⋆", mathBin}, "circ": {"∘", mathBin},
+	"bullet": {"•", mathBin}, "cup": {"∪", mathBin}, "cap": {"∩", mathBin}, "setminus": {"∖", mathBin},
+	"wedge": {"∧", mathBin}, "land": {"∧", mathBin}, "vee": {"∨", mathBin}, "lor": {"∨", mathBin},
+	"oplus": {"⊕", mathBin}, "ominus": {"⊖", mathBin}, "otimes": {"⊗", mathBin}, "odot": {"⊙", mathBin},
+
+	// 关系符
+	"le": {"≤", mathRel}, "leq": {"≤", mathRel}, "ge": {"≥", mathRel}, "geq": {"≥", mathRel},
+	"ne": {"≠", mathRel}, "neq": {"≠", mathRel}, "approx": {"≈", mathRel}, "equiv": {"≡", mathRel},
+	"sim": {"∼", mathRel}, "simeq": {"≃", mathRel}, "cong": {"≅", mathRel}, "propto": {"∝", mathRel},
+	"ll": {"≪", mathRel}, "gg": {"≫", mathRel}, "in": {"∈", mathRel}, "notin": {"∉", mathRel},
+	"ni": {"∋", mathRel}, "subset": {"⊂", mathRel}, "supset": {"⊃", mathRel}, "subseteq": {"⊆", mathRel},
+	"supseteq": {"⊇", mathRel}, "mid": {"|", mathRel}, "parallel": {"∥", mathRel}, "perp": {"⊥", mathRel},
+	"to": {"→", mathRel}, "rightarrow": {"→", mathRel}, "leftarrow": {"←", mathRel}, "gets": {"←", mathRel},
+	"leftrightarrow": {"↔", mathRel}, "Rightarrow": {"⇒", mathRel}, "Leftarrow": {"⇐", mathRel},
+	"Leftrightarrow": {"⇔", mathRel}, "implies": {"⇒", mathRel}, "iff": {"⇔", mathRel}, "mapsto": {"↦", mathRel},
+	"longrightarrow": {"⟶", mathRel}, "longleftarrow": {"⟵", mathRel}, "Longrightarrow": {"⟹", mathRel},
+	"uparrow": {"↑", mathRel}, "downarrow": {"↓", mathRel}, "coloneqq": {"≔", mathRel}, "vdash": {"⊢", mathRel},
+	"models": {"⊨", mathRel}, "prec": {"≺", mathRel}, "succ": {"≻", mathRel}, "asymp": {"≍", mathRel},
+
+	// 标点
+	"colon": {":", mathPunct}, "ldotp": {".", mathPunct},
+}
+
+var mathLargeOps = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+	"bigcup": "⋃", "bigcap": "⋂", "bigvee": "⋁", "bigwedge": "⋀", "bigoplus": "⨁", "bigotimes": "⨂",
+}
+
+// mathFunctions 为函数名，值表示在行间公式中是否将上下标放在正上方和正下方。
+var mathFunctions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "cot": false, "sec": false, "csc": false,
+	"arcsin": false, "arccos": false, "arctan": false, "sinh": false, "cosh": false, "tanh": false, "coth": false,
+	"log": false, "ln": false, "lg": false, "exp": false, "arg": false, "deg": false, "dim": false,
+	"hom": false, "ker": false,
+	"lim": true, "liminf": true, "limsup": true, "max": true, "min": true, "sup": true, "inf": true,
+	"det": true, "gcd": true, "Pr": true, "argmax": true, "argmin": true,
+}
+
+var mathSpaces = map[string]float64{
+	",": 3.0 / 18, "thinspace": 3.0 / 18, ":": 4.0 / 18, ">": 4.0 / 18, "medspace": 4.0 / 18,
+	";": 5.0 / 18, "thickspace": 5.0 / 18, "!": -3.0 / 18, "negthinspace": -3.0 / 18,
+	" ": 0.25, "quad": 1, "qquad": 2, "enspace": 0.5,
+}
+
+var mathAccents = map[string]string{
+	"hat": "hat", "widehat": "hat", "bar": "bar", "overline": "bar", "vec": "vec", "overrightarrow": "vec",
+	"dot": "dot", "ddot": "ddot", "tilde": "tilde", "widetilde": "tilde", "underline": "underline",
+	"check": "check", "breve": "check", "acute": "acute", "grave": "grave",
+}
+
+var mathWideAccents = map[string]bool{
+	"widehat": true, "overline": true, "overrightarrow": true, "widetilde": true,
+}
+
+var mathDelimSizes = map[string]float64{
+	"big": 1.2, "Big": 1.8, "bigg": 2.4, "Bigg": 3,
+}
+
+var mathDelimiters = map[string]string{
+	"{": "{", "}": "}", "|": "‖", "langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "lbrace": "{", "rbrace": "}", "vert": "|", "Vert": "‖", "lvert": "|",
+	"rvert": "|", "lVert": "‖", "rVert": "‖",
+}
+
+var mathDoubleStruck = map[string]string{
+	"C": "ℂ", "H": "ℍ", "N": "ℕ", "P": "ℙ", "Q": "ℚ", "R": "ℝ", "Z": "ℤ",
+}
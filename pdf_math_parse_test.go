@@ -0,0 +1,140 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// dumpMath 将节点列表输出为便于比较的文本：符号直接输出，其他节点输出为 kind(...)，无法识别的内容输出为 !text。
+func dumpMath(list []*mathNode) string {
+	buf := &strings.Builder{}
+	for _, n := range list {
+		switch n.kind {
+		case mathNodeSymbol:
+			buf.WriteString(n.text)
+		case mathNodeText:
+			if n.error {
+				buf.WriteString("!")
+			}
+			buf.WriteString(n.text)
+		case mathNodeGroup:
+			buf.WriteString("{" + dumpMath(n.body) + "}")
+		case mathNodeFrac:
+			buf.WriteString(n.text + "(" + dumpMath(n.body) + "," + dumpMath(n.body2) + ")")
+		case mathNodeSqrt:
+			buf.WriteString("sqrt(" + dumpMath(n.body2) + "," + dumpMath(n.body) + ")")
+		case mathNodeLeftRight:
+			buf.WriteString("left" + n.left + dumpMath(n.body) + "right" + n.right)
+		case mathNodeAccent:
+			if n.wide {
+				buf.WriteString("wide")
+			}
+			buf.WriteString(n.text + "(" + dumpMath(n.body) + ")")
+		case mathNodeMatrix:
+			var rows []string
+			for _, row := range n.rows {
+				var cells []string
+				for _, cell := range row {
+					cells = append(cells, dumpMath(cell))
+				}
+				rows = append(rows, strings.Join(cells, "&"))
+			}
+			buf.WriteString(n.text + "[" + strings.Join(rows, `\\`) + "]")
+		default:
+			buf.WriteString("?")
+		}
+		if n.hasSup {
+			buf.WriteString("^" + dumpMath(n.sup))
+		}
+		if n.hasSub {
+			buf.WriteString("_" + dumpMath(n.sub))
+		}
+	}
+	return buf.String()
+}
+
+func TestParseMath(t *testing.T) {
+	tests := []struct {
+		tex  string
+		want string
+	}{
+		// 括号不匹配
+		{"{a+b", "{a+b}"},
+		{"a}+b", "a!}+b"},
+		{"a}}b", "a!}!}b"},
+		{"{a}}b", "{a}!}b"},
+		{"x^{2", "x^2"},
+		{"a} & b", "aligned[a!}&b]"},
+		{`a \end{x} b`, `a!\end{x}b`},
+
+		// \left 和 \right 不匹配
+		{`\left( a`, "left(aright."},
+		{`\left( a } b`, "left(aright.!}b"},
+		{`\left( a \\ b`, `gathered[left(aright.\\b]`},
+		{`a \right) b`, `a!\right)b`},
+		{`\left[ a \right]`, "left[aright]"},
+
+		// \frac 缺少参数
+		{`\frac{a}`, "frac(a,)"},
+		{`\frac`, "frac(,)"},
+		{`{\frac{a}}b`, "{frac(a,)}b"},
+		{`\frac{a}\\b`, `gathered[frac(a,)\\b]`},
+		{`\frac{a}&b`, "aligned[frac(a,)&b]"},
+		{`\frac{a}\right)`, `frac(a,)!\right)`},
+		{`\frac ab`, "frac(a,b)"},
+
+		// 嵌套 \sqrt[n]{}
+		{`\sqrt{x}`, "sqrt(,x)"},
+		{`\sqrt[n]{x}`, "sqrt(n,x)"},
+		{`\sqrt[n]{\sqrt[m]{x}}`, "sqrt(n,sqrt(m,x))"},
+		{`\sqrt[\sqrt[3]{n}]{x}`, "sqrt(sqrt(3,n),x)"},
+		{`\sqrt[{]}]{x}`, "sqrt({]},x)"},
+		{`\sqrt[a`, "sqrt(a,)"},
+		{`\sqrt[a\`, `sqrt(a!\,)`},
+
+		// 重复的上下标
+		{"x^a^b", "x^ab"},
+		{"x_a_b", "x_ab"},
+		{"x_a^b_c", "x^b_ac"},
+
+		// 横跨整个内容的重音
+		{`\hat{x}`, "hat(x)"},
+		{`\widehat{xy}`, "widehat(xy)"},
+		{`\widetilde{xy}`, "widetilde(xy)"},
+		{`\overrightarrow{AB}`, "widevec(AB)"},
+	}
+	for _, test := range tests {
+		list, _ := parseMath(test.tex)
+		if got := dumpMath(list); test.want != got {
+			t.Errorf("parseMath(%q) = %q, want %q", test.tex, got, test.want)
+		}
+	}
+}
+
+func TestParseMathEnvironment(t *testing.T) {
+	tests := []struct {
+		tex  string
+		want string
+	}{
+		{`\begin{matrix} a & b \\ c & d \end{matrix}`, `matrix[a&b\\c&d]`},
+		{`\begin{matrix} a } & b \end{matrix} c`, `matrix[a!}&b]c`},
+		{`\begin{matrix} a \right) \end{matrix}`, `matrix[a!\right)]`},
+		{`\begin{matrix} a & b`, "matrix[a&b]"},
+	}
+	for _, test := range tests {
+		list, _ := parseMath(test.tex)
+		if got := dumpMath(list); test.want != got {
+			t.Errorf("parseMath(%q) = %q, want %q", test.tex, got, test.want)
+		}
+	}
+}
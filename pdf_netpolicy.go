@@ -0,0 +1,272 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// PdfNetPolicy 描述了下载网络图片时的网络策略，渲染不可信的 Markdown 时可以用来限制访问范围和资源占用。
+type PdfNetPolicy struct {
+	Offline          bool          // 离线模式，不发起任何网络请求，只使用已缓存的图片
+	AllowHosts       []string      // 允许下载的主机名（包括子域名），为空时不限制
+	DenyHosts        []string      // 禁止下载的主机名（包括子域名），优先于 AllowHosts
+	BlockPrivate     bool          // 是否禁止访问回环、内网和链路本地等私有地址
+	Proxy            string        // 代理地址，为空时使用 HTTP_PROXY、HTTPS_PROXY 和 NO_PROXY 环境变量
+	MaxSize          int64         // 单个图片的最大字节数，小于等于 0 时不限制
+	CheckContentType bool          // 是否检查响应的 Content-Type，只接受 image/*、application/octet-stream 或者没有声明类型
+	Timeout          time.Duration // 单次请求的超时时间
+	Retries          int           // 网络错误、服务端错误（5xx）或者限流（429）时的重试次数
+
+	clientOnce sync.Once    // 第一次请求时按照上面的配置创建客户端
+	httpClient *http.Client // 共用的 HTTP 客户端
+	clientErr  error        // 创建客户端的错误
+}
+
+// NewPdfNetPolicy 创建默认的网络策略：30 秒超时，重试 1 次，单个图片不超过 20MB，检查 Content-Type。
+func NewPdfNetPolicy() *PdfNetPolicy {
+	return &PdfNetPolicy{
+		MaxSize:          20 << 20,
+		CheckContentType: true,
+		Timeout:          30 * time.Second,
+		Retries:          1,
+	}
+}
+
+// errImgOffline 表示离线模式下图片没有缓存。
+var errImgOffline = errors.New("offline mode and image is not cached")
+
+// errImgBlocked 表示网络策略不允许访问该地址，这类错误不会重试。
+var errImgBlocked = errors.New("blocked by net policy")
+
+// privateNets 是 BlockPrivate 开启时禁止访问的网段，回环、链路本地和未指定地址另外判断。
+var privateNets = parseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+
+func parseCIDRs(cidrs ...string) (ret []*net.IPNet) {
+	for _, cidr := range cidrs {
+		_, ipNet, _ := net.ParseCIDR(cidr)
+		ret = append(ret, ipNet)
+	}
+	return
+}
+
+// privateIP 判断 ip 是否是回环、内网、链路本地或者未指定地址。
+func privateIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, ipNet := range privateNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHost 判断主机名 host 是否是 hosts 之一或者其子域名，不区分大小写。
+//
+// hosts 中以 *. 开头的项只匹配子域名，比如 *.example.com 匹配 img.example.com 但不匹配 example.com。
+func matchHost(host string, hosts []string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, h := range hosts {
+		h = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(h)), ".")
+		if strings.HasPrefix(h, "*.") {
+			if "*." != h && strings.HasSuffix(host, h[1:]) {
+				return true
+			}
+			continue
+		}
+		h = strings.TrimPrefix(h, ".")
+		if "" != h && (host == h || strings.HasSuffix(host, "."+h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHost 检查地址 u 的协议和主机名是否允许访问，不解析主机名。
+func (policy *PdfNetPolicy) checkHost(u *url.URL) error {
+	if "http" != u.Scheme && "https" != u.Scheme {
+		return fmt.Errorf("%w: scheme [%s] is not allowed", errImgBlocked, u.Scheme)
+	}
+	host := u.Hostname()
+	if matchHost(host, policy.DenyHosts) {
+		return fmt.Errorf("%w: host [%s] is denied", errImgBlocked, host)
+	}
+	if 0 < len(policy.AllowHosts) && !matchHost(host, policy.AllowHosts) {
+		return fmt.Errorf("%w: host [%s] is not allowed", errImgBlocked, host)
+	}
+	return nil
+}
+
+// checkURL 检查地址 u 是否允许访问，BlockPrivate 开启时会解析主机名并检查所有地址。
+func (policy *PdfNetPolicy) checkURL(u *url.URL) error {
+	if err := policy.checkHost(u); nil != err {
+		return err
+	}
+	if !policy.BlockPrivate {
+		return nil
+	}
+
+	host := u.Hostname()
+	ips := []net.IP{net.ParseIP(host)}
+	if nil == ips[0] {
+		addrs, err := net.LookupIP(host)
+		if nil != err {
+			return err
+		}
+		ips = addrs
+	}
+	for _, ip := range ips {
+		if privateIP(ip) {
+			return fmt.Errorf("%w: host [%s] resolves to private address [%s]", errImgBlocked, host, ip)
+		}
+	}
+	return nil
+}
+
+// client 返回按照网络策略创建的 HTTP 客户端，所有请求共用同一个连接池。
+//
+// BlockPrivate 开启时所有直连（包括重定向到 NO_PROXY 中主机的请求）在建立连接前都会检查实际连接的地址，避免 DNS 重绑定绕过检查；
+// 只有连接代理服务器时不检查，代理服务器负责解析主机名，只能依赖 checkURL 的检查。
+func (policy *PdfNetPolicy) client() (*http.Client, error) {
+	policy.clientOnce.Do(func() {
+		proxy := http.ProxyFromEnvironment
+		if "" != policy.Proxy {
+			proxyURL, err := url.Parse(policy.Proxy)
+			if nil != err {
+				policy.clientErr = fmt.Errorf("invalid proxy [%s]: %w", policy.Proxy, err)
+				return
+			}
+			proxy = http.ProxyURL(proxyURL)
+		}
+
+		// 记录使用过的代理服务器地址，连接这些地址时不检查私有地址
+		var proxyAddrs sync.Map
+		transportProxy := func(req *http.Request) (*url.URL, error) {
+			proxyURL, err := proxy(req)
+			if nil == err && nil != proxyURL {
+				proxyAddrs.Store(proxyAddr(proxyURL), true)
+			}
+			return proxyURL, err
+		}
+
+		dialer := &net.Dialer{Timeout: policy.Timeout}
+		guarded := &net.Dialer{Timeout: policy.Timeout}
+		if policy.BlockPrivate {
+			guarded.Control = func(network, address string, c syscall.RawConn) error {
+				host, _, _ := net.SplitHostPort(address)
+				if ip := net.ParseIP(host); nil != ip && privateIP(ip) {
+					return fmt.Errorf("%w: private address [%s]", errImgBlocked, ip)
+				}
+				return nil
+			}
+		}
+		transport := &http.Transport{
+			Proxy: transportProxy,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				if _, ok := proxyAddrs.Load(addr); ok {
+					return dialer.DialContext(ctx, network, addr)
+				}
+				return guarded.DialContext(ctx, network, addr)
+			},
+			TLSHandshakeTimeout: policy.Timeout,
+		}
+		policy.httpClient = &http.Client{
+			Transport: transport,
+			Timeout:   policy.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if 10 <= len(via) {
+					return errors.New("stopped after 10 redirects")
+				}
+				return policy.checkURL(req.URL)
+			},
+		}
+	})
+	return policy.httpClient, policy.clientErr
+}
+
+// proxyAddr 返回代理服务器 proxyURL 的连接地址（host:port），没有端口时使用协议的默认端口。
+func proxyAddr(proxyURL *url.URL) string {
+	port := proxyURL.Port()
+	if "" == port {
+		switch proxyURL.Scheme {
+		case "https":
+			port = "443"
+		case "socks5":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// do 按照网络策略发送请求 req，网络错误、服务端错误或者限流时按照 Retries 重试。
+func (policy *PdfNetPolicy) do(req *http.Request) (*http.Response, error) {
+	if err := policy.checkURL(req.URL); nil != err {
+		return nil, err
+	}
+	client, err := policy.client()
+	if nil != err {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(req)
+		retry := (nil != err && !errors.Is(err, errImgBlocked)) || (nil == err && (500 <= resp.StatusCode || http.StatusTooManyRequests == resp.StatusCode))
+		if !retry || attempt >= policy.Retries {
+			return resp, err
+		}
+		if nil != resp {
+			resp.Body.Close()
+		}
+		time.Sleep(time.Duration(attempt+1) * 500 * time.Millisecond)
+	}
+}
+
+// readBody 读取图片响应 resp 的内容，检查 Content-Type 和大小。
+func (policy *PdfNetPolicy) readBody(resp *http.Response) ([]byte, error) {
+	if policy.CheckContentType {
+		if contentType := resp.Header.Get("Content-Type"); "" != contentType {
+			mediaType, _, _ := mime.ParseMediaType(contentType)
+			if !strings.HasPrefix(mediaType, "image/") && "application/octet-stream" != mediaType {
+				return nil, fmt.Errorf("content type [%s] is not an image", contentType)
+			}
+		}
+	}
+	if 0 >= policy.MaxSize {
+		return ioutil.ReadAll(resp.Body)
+	}
+	if resp.ContentLength > policy.MaxSize {
+		return nil, fmt.Errorf("content length [%d] exceeds the max size [%d]", resp.ContentLength, policy.MaxSize)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, policy.MaxSize+1))
+	if nil != err {
+		return nil, err
+	}
+	if int64(len(data)) > policy.MaxSize {
+		return nil, fmt.Errorf("image size exceeds the max size [%d]", policy.MaxSize)
+	}
+	return data, nil
+}
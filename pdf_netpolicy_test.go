@@ -0,0 +1,122 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestPrivateIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		private bool
+	}{
+		// IPv4
+		{"127.0.0.1", true},
+		{"127.255.255.254", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"172.15.255.255", false},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"8.8.8.8", false},
+		{"1.2.3.4", false},
+		// IPv6
+		{"::1", true},
+		{"::", true},
+		{"fe80::1", true},
+		{"ff02::1", true},
+		{"fc00::1", true},
+		{"fd12:3456:789a::1", true},
+		{"2001:4860:4860::8888", false},
+		// IPv4 映射的 IPv6 地址
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"::ffff:169.254.169.254", true},
+		{"::ffff:8.8.8.8", false},
+	}
+	for _, test := range tests {
+		ip := net.ParseIP(test.ip)
+		if nil == ip {
+			t.Fatalf("invalid test ip [%s]", test.ip)
+		}
+		if got := privateIP(ip); test.private != got {
+			t.Errorf("privateIP(%s) = %v, want %v", test.ip, got, test.private)
+		}
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		host  string
+		hosts []string
+		match bool
+	}{
+		{"example.com", []string{"example.com"}, true},
+		{"img.example.com", []string{"example.com"}, true},
+		{"a.b.example.com", []string{"example.com"}, true},
+		{"badexample.com", []string{"example.com"}, false},
+		{"example.com.evil.org", []string{"example.com"}, false},
+		{"example.org", []string{"example.com"}, false},
+		{"img.example.com", []string{".example.com"}, true},
+		{"example.com", []string{".example.com"}, true},
+		{"img.example.com", []string{"*.example.com"}, true},
+		{"example.com", []string{"*.example.com"}, false},
+		{"badexample.com", []string{"*.example.com"}, false},
+		{"example.com", []string{"*."}, false},
+		{"IMG.Example.COM", []string{"example.com"}, true},
+		{"img.example.com", []string{" EXAMPLE.com "}, true},
+		{"example.com.", []string{"example.com"}, true},
+		{"example.com", []string{"", "other.com", "example.com"}, true},
+		{"example.com", []string{""}, false},
+		{"example.com", nil, false},
+		{"127.0.0.1", []string{"127.0.0.1"}, true},
+		{"127.0.0.10", []string{"127.0.0.1"}, false},
+	}
+	for _, test := range tests {
+		if got := matchHost(test.host, test.hosts); test.match != got {
+			t.Errorf("matchHost(%q, %q) = %v, want %v", test.host, test.hosts, got, test.match)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	policy := &PdfNetPolicy{AllowHosts: []string{"example.com"}, DenyHosts: []string{"private.example.com"}}
+	tests := []struct {
+		link string
+		ok   bool
+	}{
+		{"https://img.example.com/a.png", true},
+		{"http://example.com/a.png", true},
+		{"https://private.example.com/a.png", false},
+		{"https://a.private.example.com/a.png", false},
+		{"https://example.org/a.png", false},
+		{"ftp://example.com/a.png", false},
+		{"file:///etc/passwd", false},
+	}
+	for _, test := range tests {
+		u, _ := url.Parse(test.link)
+		err := policy.checkHost(u)
+		if test.ok != (nil == err) {
+			t.Errorf("checkHost(%s) = %v, want ok %v", test.link, err, test.ok)
+		}
+		if nil != err && !errors.Is(err, errImgBlocked) {
+			t.Errorf("checkHost(%s) = %v, want blocked error", test.link, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"regexp"
+)
+
+// crossRef 匹配正文中的交叉引用：\eqref{label}、\ref{label}、[@eq:label] 和 [@fig:label]。
+var crossRef = regexp.MustCompile(`\\(eqref|ref)\{([^{}]+)\}|\[@((?:eq|fig):[^\[\]\s]+)\]`)
+
+// refText 返回引用标签 label 时显示的文本和链接锚点，eqref 为 true 时公式编号带括号，没有该标签时锚点为空。
+func (r *PdfRenderer) refText(label string, eqref bool) (text, anchor string) {
+	if text, ok := r.equationRefText(label, eqref); ok {
+		return text, equationAnchor(label)
+	}
+	if text, ok := r.figureRefText(label); ok {
+		return text, figureAnchor(label)
+	}
+	logger.Warnf("reference label [%s] not found", label)
+	if eqref {
+		return "(??)", ""
+	}
+	return "??", ""
+}
+
+// renderCrossRefs 输出包含交叉引用的文本 text，引用替换为公式或者图片编号并链接到其所在位置。
+func (r *PdfRenderer) renderCrossRefs(text string) {
+	for {
+		loc := crossRef.FindStringSubmatchIndex(text)
+		if nil == loc {
+			r.WriteString(text)
+			return
+		}
+
+		r.WriteString(text[:loc[0]])
+		var label string
+		eqref := true
+		if 0 <= loc[2] {
+			label = text[loc[4]:loc[5]]
+			eqref = "eqref" == text[loc[2]:loc[3]]
+		} else {
+			label = text[loc[6]:loc[7]]
+		}
+		refText, anchor := r.refText(label, eqref)
+		if "" == anchor {
+			r.WriteString(refText)
+		} else {
+			r.pushTextColor(&RGB{66, 133, 244})
+			x, y := r.pdf.GetX(), r.pdf.GetY()
+			r.WriteString(refText)
+			if y != r.pdf.GetY() {
+				x, y = r.peekBox().left, r.pdf.GetY()
+			}
+			r.pdf.AddInternalLink(anchor, x, y, r.pdf.GetX()-x, r.lineHeight)
+			r.popTextColor()
+		}
+		text = text[loc[1]:]
+	}
+}
@@ -16,12 +16,9 @@ import (
 	"image"
 	"io/ioutil"
 	"math"
-	"net/http"
-	"net/url"
-	"os"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/lex"
@@ -29,32 +26,94 @@ import (
 	"github.com/88250/lute/render"
 	"github.com/88250/lute/util"
 	"github.com/signintech/gopdf"
+	"github.com/signintech/gopdf/fontmaker/core"
 )
 
 // PdfRenderer 描述了 PDF 渲染器。
 type PdfRenderer struct {
 	*render.BaseRenderer
 
-	Cover       *PdfCover // 封面
-	RegularFont string    // 正常字体文件路径
-	BoldFont    string    // 粗体字体文件路径
-	ItalicFont  string    // 斜体字体文件路径
-
-	pdf          *gopdf.GoPdf // PDF 生成器句柄
-	pageSize     *gopdf.Rect  // 页面大小
-	zoom         float64      // 字体、行高大小倍数
-	fontSize     int          // 字体大小
-	lineHeight   float64      // 行高
-	heading1Size float64      // 一级标题大小
-	heading2Size float64      // 二级标题大小
-	heading3Size float64      // 三级标题大小
-	heading4Size float64      // 四级标题大小
-	heading5Size float64      // 五级标题大小
-	heading6Size float64      // 六级标题大小
-	margin       float64      // 页边距
-	x            []float64    // 当前横坐标栈
-	fonts        []*Font      // 当前字体栈
-	textColors   []*RGB       // 当前文本颜色栈
+	Cover           *PdfCover              // 封面
+	RegularFont     string                 // 正常字体文件路径
+	BoldFont        string                 // 粗体字体文件路径
+	ItalicFont      string                 // 斜体字体文件路径
+	BlockquoteStyle *PdfBoxStyle           // 引述样式
+	Callouts        map[string]*PdfCallout // 提示块样式，键为小写类型名
+
+	ListBullets      []string // 无序列表各嵌套层级的符号，层级超出时循环使用
+	ListNumberStyles []string // 有序列表各嵌套层级的编号样式：1、a、A、i、I，层级超出时循环使用
+
+	TaskListCheckedGray   bool // 已完成的任务列表项是否使用灰色文本
+	TaskListCheckedStrike bool // 已完成的任务列表项是否添加删除线
+	TaskListFormField     bool // 任务列表项复选框是否渲染为可交互的表单域
+
+	MathAutoNumber bool // 是否为所有公式块自动编号，否则只为带 \label{} 的公式块编号
+
+	ImageDPI      float64 // 图片没有记录分辨率时使用的分辨率（每英寸像素数）
+	ImageMaxScale float64 // 没有指定尺寸的图片的最大放大倍数，图片会等比缩放到内容区域以内，默认为 1 即不放大
+
+	ImageCaption       bool   // 是否在独占一个段落的图片下方输出带编号的题注
+	FigureLabel        string // 题注编号前缀，比如 Figure、图
+	ListOfFigures      bool   // 是否在正文前输出图片目录，需要开启 ImageCaption
+	ListOfFiguresTitle string // 图片目录标题
+
+	ImageMaxDPI      float64 // 输出图片的最大分辨率（按照打印尺寸计算每英寸像素数），超过时降采样，小于等于 0 时不降采样
+	ImageJPEGQuality int     // 不透明照片重新压缩为 JPEG 的质量（1-100），小于等于 0 时不重新压缩
+	ImageGrayscale   bool    // 是否将图片转换为灰度，用于黑白打印
+
+	ImageWorkers  int    // 并发下载图片的协程数
+	ImageCacheDir string // 图片缓存目录，为空时只在本次转换中使用临时目录
+	ImageRoot     string // 本地图片相对路径的基准目录，为空时使用当前工作目录
+	ImageSandbox  bool   // 是否将本地图片限制在 ImageRoot 目录内，禁止绝对路径、file:// 地址以及通过 .. 或者符号链接跳出该目录
+
+	ImageRewriteRules []*PdfImageRewriteRule // 网络图片地址改写规则，使用第一条匹配的规则，默认为七牛云图片处理预设
+	NetPolicy         *PdfNetPolicy          // 下载网络图片时的网络策略
+
+	ImageResolver ImageResolver // 图片加载器，默认为渲染器本身，即按照网络地址、data: URI 和本地路径加载
+
+	EmojiDir string // Emoji 图片目录，图片按照 Twemoji 的规则以码点命名，比如 1f600.png
+	EmojiCDN string // Emoji 图片地址前缀，EmojiDir 中没有对应图片时从这里下载，为空时不下载
+
+	pdf          *gopdf.GoPdf       // PDF 生成器句柄
+	pageSize     *gopdf.Rect        // 页面大小
+	zoom         float64            // 字体、行高大小倍数
+	fontSize     int                // 字体大小
+	lineHeight   float64            // 行高
+	heading1Size float64            // 一级标题大小
+	heading2Size float64            // 二级标题大小
+	heading3Size float64            // 三级标题大小
+	heading4Size float64            // 四级标题大小
+	heading5Size float64            // 五级标题大小
+	heading6Size float64            // 六级标题大小
+	margin       float64            // 页边距
+	ascent       float64            // 正常字体上升部高度与字号之比，用于计算基线位置
+	listIndent   float64            // 列表缩进
+	x            []float64          // 当前横坐标栈
+	boxes        []*Box             // 当前块级盒子栈
+	strike       int                // 删除线嵌套计数，大于 0 时输出的文本带删除线
+	formFields   []*formField       // 交互式表单域
+	decorations  []*boxDecoration   // 带装饰样式的盒子在各页上的装饰，保存时绘制在页面内容之前
+	skipped      map[*ast.Node]bool // 渲染时跳过的节点，比如提示块的 [!TYPE] 标记
+	textOffsets  map[*ast.Node]int  // 文本节点绘制时跳过的开头字节数，比如图片后的标签标记 {#fig:label}、任务列表标记后的空格
+
+	equations      map[*ast.Node]*mathEquation // 公式块内容节点对应的编号信息
+	equationLabels map[string]*mathEquation    // 公式标签对应的编号信息
+	figures        map[*ast.Node]*figure       // 图片节点对应的题注信息
+	figureLabels   map[string]*figure          // 图片标签对应的题注信息
+	figureList     []*figure                   // 按照编号排列的带题注图片
+	emojis         map[string][]byte           // Emoji 图片缓存，键为图片文件名，值为 nil 表示没有图片
+	imgFailures    []*PdfImageFailure          // 加载失败的图片，按照失败的先后顺序排列
+	svgs           map[string]*svgImage        // SVG 图片缓存，键同 imgData
+	imgData        map[string][]byte           // 已加载的图片数据，键由 imgKey 生成，值为 nil 表示加载失败
+	imgs           map[string][]byte           // 重新编码为 PNG 的图片数据，键同 imgData
+	optimizedImgs  map[string]*optimizedImg    // 按照输出选项处理后的图片，键为图片数据的 SHA-1
+	fonts          []*Font                     // 当前字体栈
+	textColors     []*RGB                      // 当前文本颜色栈
+
+	downloads       map[string]*imgDownload // 网络图片下载任务，键为下载地址
+	downloadsLock   sync.Mutex              // 保护 downloads 和 tempImgCacheDir
+	fetching        sync.WaitGroup          // 后台预下载协程
+	tempImgCacheDir string                  // 没有配置缓存目录时使用的临时目录
 }
 
 // PdfCover 描述了 PDF 封面。
@@ -79,12 +138,25 @@ type PdfCover struct {
 func (r *PdfRenderer) RenderCover() {
 	r.pdf.AddPage()
 
-	logoImgPath, ok, isTemp := r.downloadImg(r.Cover.LogoLink)
+	// 没有配置图标时不输出图标和图标标题
+	var logoImgPath string
+	ok := false
+	if "" != r.Cover.LogoLink {
+		logoImgPath, ok = r.loadImg(r.Cover.LogoLink)
+	}
+	var imgW, imgH float64
+	if ok {
+		var err error
+		if imgW, imgH, err = r.getImgSize(logoImgPath, false); nil != err {
+			logger.Warnf("load cover logo failed: %s", err)
+			r.addImgFailure(r.Cover.LogoLink, err)
+			ok = false
+		}
+	}
 	if ok {
-		imgW, imgH := r.getImgSize(logoImgPath)
 		x := (r.pageSize.W)/2 - imgW/2
 		y := r.pageSize.H/2 - r.margin - 128
-		r.pdf.Image(logoImgPath, x, y, nil)
+		r.drawImg(logoImgPath, x, y, imgW, imgH)
 		r.pdf.SetY(y)
 		r.pdf.Br(imgH + 10)
 		r.pdf.SetFontWithStyle("regular", gopdf.Regular, 20)
@@ -95,9 +167,6 @@ func (r *PdfRenderer) RenderCover() {
 		r.pdf.Cell(nil, r.Cover.LogoTitle)
 		r.pdf.AddExternalLink(r.Cover.LogoTitleLink, x, y, width, 20)
 		r.pdf.Br(48)
-		if isTemp {
-			os.Remove(logoImgPath)
-		}
 	}
 
 	r.pdf.SetFontWithStyle("regular", gopdf.Regular, 28)
@@ -153,6 +222,14 @@ func (r *PdfRenderer) RenderCover() {
 	r.pdf.AddPage()
 }
 
+// PdfBoxStyle 描述了块级盒子（比如引述）的装饰样式。
+type PdfBoxStyle struct {
+	BorderColor *RGB    // 左边框颜色，为空时不绘制左边框
+	BorderWidth float64 // 左边框宽度
+	Background  *RGB    // 背景色，为空时不填充背景
+	Padding     float64 // 内边距
+}
+
 // NewPdfRenderer 创建一个 HTML 渲染器。
 func NewPdfRenderer(tree *parse.Tree, options *render.Options, regularFont, boldFont, italicFont string) *PdfRenderer {
 	pdf := &gopdf.GoPdf{}
@@ -168,10 +245,37 @@ func NewPdfRenderer(tree *parse.Tree, options *render.Options, regularFont, bold
 	ret.heading5Size = 16 * ret.zoom
 	ret.heading6Size = 14 * ret.zoom
 	ret.margin = 60 * ret.zoom
+	ret.listIndent = 16 * ret.zoom
 
 	ret.RegularFont = regularFont
 	ret.BoldFont = boldFont
 	ret.ItalicFont = italicFont
+	ret.BlockquoteStyle = &PdfBoxStyle{
+		BorderColor: &RGB{223, 226, 229},
+		BorderWidth: 3,
+		Padding:     10,
+	}
+	ret.Callouts = NewCallouts()
+	ret.ListBullets = []string{"●", "○", "■"}
+	ret.ListNumberStyles = []string{"1", "a", "i", "A"}
+	ret.ImageDPI = 128
+	ret.ImageMaxScale = 1
+	ret.FigureLabel = "Figure"
+	ret.ListOfFiguresTitle = "List of Figures"
+	ret.EmojiCDN = "https://cdn.jsdelivr.net/gh/twitter/twemoji@14.0.2/assets/72x72"
+	ret.skipped = map[*ast.Node]bool{}
+	ret.textOffsets = map[*ast.Node]int{}
+	ret.emojis = map[string][]byte{}
+	ret.svgs = map[string]*svgImage{}
+	ret.imgs = map[string][]byte{}
+	ret.imgData = map[string][]byte{}
+	ret.optimizedImgs = map[string]*optimizedImg{}
+	ret.ImageResolver = ret
+	ret.ImageRewriteRules = NewQiniuImageRewriteRules()
+	ret.NetPolicy = NewPdfNetPolicy()
+	ret.downloads = map[string]*imgDownload{}
+	ret.ImageWorkers = 4
+	ret.ImageCacheDir = defaultImgCacheDir()
 
 	ret.pageSize = gopdf.PageSizeA4
 	pdf.Start(gopdf.Config{PageSize: *ret.pageSize})
@@ -192,13 +296,15 @@ func NewPdfRenderer(tree *parse.Tree, options *render.Options, regularFont, bold
 		logger.Fatal(err)
 	}
 
-	//err = pdf.AddTTFFont("emoji", "fonts/seguiemj.ttf")
-	//if err != nil {
-	//	logger.Fatal(err)
-	//}
+	ttf := core.TTFParser{}
+	if err = ttf.Parse(ret.RegularFont); nil != err {
+		logger.Fatal(err)
+	}
+	ret.ascent = float64(ttf.TypoAscender()) / float64(ttf.UnitsPerEm())
 
 	ret.pushFont(&Font{"regular", "R", ret.fontSize})
 	ret.pushTextColor(&RGB{0, 0, 0})
+	ret.pushBox(&Box{left: ret.margin, right: ret.pageSize.W - ret.margin})
 	pdf.SetMargins(ret.margin, ret.margin, ret.margin, ret.margin)
 
 	ret.RendererFuncs[ast.NodeDocument] = ret.renderDocument
@@ -263,6 +369,7 @@ func NewPdfRenderer(tree *parse.Tree, options *render.Options, regularFont, bold
 	ret.RendererFuncs[ast.NodeTableHead] = ret.renderTableHead
 	ret.RendererFuncs[ast.NodeTableRow] = ret.renderTableRow
 	ret.RendererFuncs[ast.NodeTableCell] = ret.renderTableCell
+	ret.RendererFuncs[ast.NodeKramdownSpanIAL] = ret.renderKramdownSpanIAL
 	ret.RendererFuncs[ast.NodeEmoji] = ret.renderEmoji
 	ret.RendererFuncs[ast.NodeEmojiUnicode] = ret.renderEmojiUnicode
 	ret.RendererFuncs[ast.NodeEmojiImg] = ret.renderEmojiImg
@@ -282,6 +389,10 @@ func (r *PdfRenderer) Render() (output []byte) {
 	r.LastOut = lex.ItemNewline
 
 	ast.Walk(r.Tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if r.skipped[n] {
+			return ast.WalkSkipChildren
+		}
+
 		extRender := r.ExtRendererFuncs[n.Type]
 		if nil != extRender {
 			output, status := extRender(n, entering)
@@ -494,29 +605,31 @@ func (r *PdfRenderer) renderCodeBlockOpenMarker(node *ast.Node, entering bool) a
 
 func (r *PdfRenderer) renderEmojiAlias(node *ast.Node, entering bool) ast.WalkStatus {
 	if entering {
-		r.pushFont(&Font{"emoji", "R", r.fontSize})
-		alias := node.Tokens[1 : len(node.Tokens)-1]
-		r.Write(alias)
-		r.popFont()
+		// 没有 Emoji 图片时输出别名
+		r.Write(node.Tokens)
 	}
 	return ast.WalkContinue
 }
 
 func (r *PdfRenderer) renderEmojiImg(node *ast.Node, entering bool) ast.WalkStatus {
+	if entering {
+		if data := r.emojiSiteImg(util.BytesToStr(node.Tokens)); nil != data && r.renderInlineImg(data) {
+			return ast.WalkSkipChildren
+		}
+	}
 	return ast.WalkContinue
 }
 
 func (r *PdfRenderer) renderEmojiUnicode(node *ast.Node, entering bool) ast.WalkStatus {
 	if entering {
-		r.pushFont(&Font{"emoji", "R", r.fontSize})
-		r.Write(node.Tokens)
-		r.popFont()
+		if data := r.emojiImg(util.BytesToStr(node.Tokens)); nil != data && r.renderInlineImg(data) {
+			return ast.WalkSkipChildren
+		}
 	}
 	return ast.WalkContinue
 }
 
 func (r *PdfRenderer) renderEmoji(node *ast.Node, entering bool) ast.WalkStatus {
-	// 暂不渲染 Emoji，字体似乎有问题
 	return ast.WalkContinue
 }
 
@@ -526,7 +639,7 @@ func (r *PdfRenderer) renderInlineMathCloseMarker(node *ast.Node, entering bool)
 
 func (r *PdfRenderer) renderInlineMathContent(node *ast.Node, entering bool) ast.WalkStatus {
 	if entering {
-		r.renderCodeSpanLike(node.Tokens)
+		r.renderInlineMathBox(r.layoutMath(util.BytesToStr(node.Tokens), false))
 	}
 	return ast.WalkContinue
 }
@@ -545,7 +658,7 @@ func (r *PdfRenderer) renderMathBlockCloseMarker(node *ast.Node, entering bool)
 
 func (r *PdfRenderer) renderMathBlockContent(node *ast.Node, entering bool) ast.WalkStatus {
 	if entering {
-		r.renderCodeBlockLike(node.Tokens)
+		r.renderMathBlockBox(r.layoutMath(util.BytesToStr(node.Tokens), true), r.equations[node])
 	}
 	return ast.WalkContinue
 }
@@ -573,7 +686,8 @@ func (r *PdfRenderer) renderTableCell(node *ast.Node, entering bool) ast.WalkSta
 		//}
 		x := r.pdf.GetX()
 		cols := float64(r.tableCols(node))
-		maxWidth := (r.pageSize.W - r.margin*2) / cols
+		box := r.peekBox()
+		maxWidth := (box.right - box.left) / cols
 		if node.Parent.FirstChild != node {
 			prevWidth, _ := r.pdf.MeasureTextWidth(strings.Repeat("爱", node.Previous.TableCellContentWidth))
 			x += maxWidth - prevWidth
@@ -671,7 +785,8 @@ func (r *PdfRenderer) renderLinkSpace(node *ast.Node, entering bool) ast.WalkSta
 
 func (r *PdfRenderer) renderLinkText(node *ast.Node, entering bool) ast.WalkStatus {
 	if entering {
-		if ast.NodeImage != node.Parent.Type {
+		// 图片的替代文本（包括其中的强调等）不输出
+		if 0 == r.DisableTags {
 			r.Write(node.Tokens)
 		}
 	}
@@ -703,34 +818,129 @@ func (r *PdfRenderer) renderImage(node *ast.Node, entering bool) ast.WalkStatus
 		if 0 == r.DisableTags {
 			destTokens := node.ChildByType(ast.NodeLinkDest).Tokens
 			src := util.BytesToStr(destTokens)
-			src, ok, isTemp := r.downloadImg(src)
+			imgPath, ok := r.loadImg(src)
+			width, height := float64(imgPlaceholderWidth), float64(imgPlaceholderHeight)
 			if ok {
-				_, height := r.getImgSize(src)
-				y := r.pdf.GetY()
-				if math.Ceil(y)+height > math.Floor(r.pageSize.H-r.margin) {
-					r.addPage()
-				}
-				r.pdf.Image(src, r.pdf.GetX(), r.pdf.GetY(), nil)
-				r.pdf.SetY(r.pdf.GetY() + height)
-				if isTemp {
-					os.Remove(src)
+				var err error
+				if width, height, err = r.getImgSize(imgPath, true); nil != err {
+					logger.Warnf("load image [%s] failed: %s", shortImgSrc(src), err)
+					r.addImgFailure(src, err)
+					ok = false
+					width, height = imgPlaceholderWidth, imgPlaceholderHeight
 				}
 			}
+			// 加载失败的图片使用占位框代替，按照同样的方式排版
+			if inlineImage(node) {
+				r.renderInlineImage(node, src, imgPath, ok, width, height)
+				r.DisableTags++
+				return ast.WalkContinue
+			}
+			attrs, _ := parseImgAttrs(node)
+			box := r.peekBox()
+			width, height = r.imgDisplaySize(attrs, width, height, box.right-box.left, r.pageSize.H-r.margin*2)
+			// 块级图片不能跨页，指定的高度超出内容区域时也需要缩小
+			if maxHeight := r.pageSize.H - r.margin*2; height > maxHeight {
+				width, height = width*maxHeight/height, maxHeight
+			}
+			fig := r.figures[node]
+			if x := r.pdf.GetX(); x > box.left && (x+width > box.right || "" != attrs.align || nil != fig) {
+				r.br(r.lineHeight)
+			}
+			caption := r.figureCaptionLines(fig)
+			y := r.pdf.GetY()
+			if math.Ceil(y)+height+r.figureCaptionHeight(caption) > math.Floor(r.pageSize.H-r.margin) {
+				r.addPage()
+			}
+			x := r.pdf.GetX()
+			switch attrs.align {
+			case "center":
+				x = box.left + (box.right-box.left-width)/2
+			case "right":
+				x = box.right - width
+			}
+			if nil != fig && "" == attrs.align {
+				// 带题注的图片默认居中
+				x = box.left + (box.right-box.left-width)/2
+			}
+			if ok {
+				r.drawImg(imgPath, x, r.pdf.GetY(), width, height)
+			} else {
+				r.drawImgPlaceholder(strings.TrimSpace(node.Text()), src, x, r.pdf.GetY(), width, height)
+			}
+			if nil != fig {
+				r.renderFigureAnchor(fig, r.pdf.GetY())
+			}
+			r.pdf.SetY(r.pdf.GetY() + height)
+			if nil != fig {
+				r.renderFigureCaption(caption)
+			}
 		}
 		r.DisableTags++
 		return ast.WalkContinue
 	}
 
 	r.DisableTags--
-	if 0 == r.DisableTags {
-		//r.WriteString("\"")
-		//if title := node.ChildByType(ast.NodeLinkTitle); nil != title && nil != title.Tokens {
-		//	r.WriteString(" title=\"")
-		//	r.Write(title.Tokens)
-		//	r.WriteString("\"")
-		//}
-		//r.WriteString(" />")
+	return ast.WalkContinue
+}
+
+// renderInlineImage 在当前位置输出随文本排版的图片，底部对齐基线，放不下时先换行。
+//
+// 没有尺寸提示时缩放到文本高度，否则按照尺寸提示显示，高度可以超过文本高度，都只在超出内容宽度时等比缩小。
+func (r *PdfRenderer) renderInlineImage(node *ast.Node, src, imgPath string, ok bool, width, height float64) {
+	attrs, _ := parseImgAttrs(node)
+	box := r.peekBox()
+	maxWidth := box.right - box.left
+	if "" == attrs.width && "" == attrs.height {
+		if size := float64(r.peekFont().size); 0 < height {
+			width, height = width*size/height, size
+		}
+		if width > maxWidth {
+			width, height = maxWidth, height*maxWidth/width
+		}
+	} else {
+		width, height = r.imgDisplaySize(attrs, width, height, maxWidth, r.pageSize.H-r.margin*2)
+	}
+
+	x, baseline := r.inlineImgPos(width)
+	y := r.pdf.GetY()
+	if size := float64(r.peekFont().size); height > size {
+		// 比文本高的图片另起一行，放不下时先换页，图片顶部与行顶部对齐，并加高当前行，让后面文本的基线与图片底部对齐，
+		// 这样图片不会向上覆盖前面的行
+		if x > r.peekBox().left {
+			r.br(size + 2)
+		}
+		if r.pdf.GetY()+height > r.pageSize.H-r.margin {
+			r.addPage()
+		}
+		x, baseline = r.pdf.GetX(), r.pdf.GetY()+height
+		y = baseline - r.ascent*size
+	}
+	if ok {
+		r.drawImg(imgPath, x, baseline-height, width, height)
+	} else {
+		// 行内占位框太小，不输出替代文本和地址
+		r.drawImgPlaceholder("", "", x, baseline-height, width, height)
 	}
+	r.pdf.SetY(y)
+	r.pdf.SetX(x + width)
+	r.LastOut = ':'
+}
+
+// inlineImgPos 确定宽度为 width 的行内图片的位置，当前行放不下时先换行，返回图片左侧的横坐标和所在行的基线纵坐标。
+func (r *PdfRenderer) inlineImgPos(width float64) (x, baseline float64) {
+	if r.pdf.GetY() > r.pageSize.H-r.margin*2 {
+		r.addPage()
+	}
+	x = r.pdf.GetX()
+	if x+width > r.peekBox().right && x > r.peekBox().left {
+		r.br(float64(r.peekFont().size) + 2)
+		x = r.pdf.GetX()
+	}
+	return x, r.pdf.GetY() + r.ascent*float64(r.peekFont().size)
+}
+
+func (r *PdfRenderer) renderKramdownSpanIAL(node *ast.Node, entering bool) ast.WalkStatus {
+	// 行级属性在渲染图片等节点时使用，本身不输出
 	return ast.WalkContinue
 }
 
@@ -765,6 +975,11 @@ func (r *PdfRenderer) renderInlineHTML(node *ast.Node, entering bool) ast.WalkSt
 }
 
 func (r *PdfRenderer) renderDocument(node *ast.Node, entering bool) ast.WalkStatus {
+	if entering && !r.RenderingFootnotes {
+		r.numberEquations(node)
+		r.numberFigures(node)
+		r.renderListOfFigures()
+	}
 	if !entering {
 		r.renderFooter()
 	}
@@ -772,10 +987,28 @@ func (r *PdfRenderer) renderDocument(node *ast.Node, entering bool) ast.WalkStat
 }
 
 func (r *PdfRenderer) Save(pdfPath string) {
-	if err := r.pdf.WritePdf(pdfPath); nil != err {
+	defer r.cleanImgCache()
+	if report := r.ImageFailureReport(); "" != report {
+		logger.Warn(report)
+	}
+	data, err := r.pdf.GetBytesPdfReturnErr()
+	if nil != err {
+		logger.Fatal(err)
+	}
+	if 0 < len(r.decorations) {
+		if data, err = appendBoxDecorations(data, r.decorations, r.pageSize.H); nil != err {
+			logger.Fatal(err)
+		}
+	}
+	if 0 < len(r.formFields) {
+		if data, err = appendFormFields(data, r.formFields, r.pageSize.H); nil != err {
+			logger.Fatal(err)
+		}
+	}
+	if err = ioutil.WriteFile(pdfPath, data, 0644); nil != err {
 		logger.Fatal(err)
 	}
-	if err := r.pdf.Close(); nil != err {
+	if err = r.pdf.Close(); nil != err {
 		logger.Fatal(err)
 	}
 }
@@ -806,8 +1039,12 @@ func (r *PdfRenderer) renderParagraph(node *ast.Node, entering bool) ast.WalkSta
 
 func (r *PdfRenderer) renderText(node *ast.Node, entering bool) ast.WalkStatus {
 	if entering {
-		text := util.BytesToStr(node.Tokens)
-		r.WriteString(text)
+		text := util.BytesToStr(node.Tokens[r.textOffsets[node]:])
+		if strings.Contains(text, `\`) || strings.Contains(text, "[@") {
+			r.renderCrossRefs(text)
+		} else {
+			r.WriteString(text)
+		}
 	}
 	return ast.WalkContinue
 }
@@ -898,11 +1135,23 @@ func (r *PdfRenderer) renderStrongU8eCloseMarker(node *ast.Node, entering bool)
 func (r *PdfRenderer) renderBlockquote(node *ast.Node, entering bool) ast.WalkStatus {
 	if entering {
 		r.Newline()
+		if callout, title := r.callout(node); nil != callout {
+			r.pushTextColor(r.peekTextColor())
+			r.pushStyledBox(&PdfBoxStyle{
+				BorderColor: callout.Color,
+				BorderWidth: r.BlockquoteStyle.BorderWidth,
+				Background:  callout.Background,
+				Padding:     r.BlockquoteStyle.Padding,
+			})
+			r.renderCalloutTitle(callout, title)
+			return ast.WalkContinue
+		}
+
 		r.pushTextColor(&RGB{106, 115, 125})
-		r.pushX(r.pdf.GetX())
+		r.pushStyledBox(r.BlockquoteStyle)
 	} else {
-		x := r.popX()
-		r.pdf.SetX(r.pdf.GetX() - x + r.margin)
+		r.Newline()
+		r.popStyledBox()
 		r.popTextColor()
 		r.Newline()
 	}
@@ -951,10 +1200,9 @@ func (r *PdfRenderer) renderList(node *ast.Node, entering bool) ast.WalkStatus {
 	if entering {
 		r.Newline()
 		r.pdf.SetY(r.pdf.GetY() + 4)
-		nestedLevel := r.countParentContainerBlocks(node)
-		indent := float64(nestedLevel * 16)
-		r.pdf.SetX(r.pdf.GetX() + indent)
+		r.pushIndentBox(r.listIndent)
 	} else {
+		r.popBox()
 		r.pdf.SetY(r.pdf.GetY() + 4)
 		r.Newline()
 	}
@@ -963,36 +1211,96 @@ func (r *PdfRenderer) renderList(node *ast.Node, entering bool) ast.WalkStatus {
 
 func (r *PdfRenderer) renderListItem(node *ast.Node, entering bool) ast.WalkStatus {
 	if entering {
-		if node.Parent.FirstChild != node {
-			nestedLevel := r.countParentContainerBlocks(node) - 1
-			indent := float64(nestedLevel * 16)
-			r.pdf.SetX(r.pdf.GetX() + indent)
-		}
-
-		if 3 == node.ListData.Typ && "" != r.Options.GFMTaskListItemClass &&
-			nil != node.FirstChild && nil != node.FirstChild.FirstChild && ast.NodeTaskListItemMarker == node.FirstChild.FirstChild.Type {
-			r.WriteString(fmt.Sprintf("%s", node.ListData.Marker))
+		r.Newline()
+		left := r.peekBox().left
+		if marker := r.taskListItemMarker(node); nil != marker {
+			r.renderTaskListItemCheckbox(marker.TaskListItemChecked)
+			if marker.TaskListItemChecked {
+				if r.TaskListCheckedGray {
+					r.pushTextColor(&RGB{106, 115, 125})
+				}
+				if r.TaskListCheckedStrike {
+					r.strike++
+				}
+			}
 		} else {
-			if 0 != node.ListData.BulletChar {
-				r.WriteString("● ")
-			} else {
-				r.WriteString(fmt.Sprint(node.ListData.Num) + ". ")
+			marker := r.listItemMarker(node)
+			if 0 == node.ListData.BulletChar {
+				// 有序列表序号右对齐，这样 9. 和 10. 的分隔符能够对齐
+				font := r.peekFont()
+				r.pdf.SetFont(font.family, font.style, font.size)
+				width, _ := r.pdf.MeasureTextWidth(marker)
+				r.pdf.SetX(left + r.listMarkerMaxWidth(node.Parent) - width)
 			}
+			r.WriteString(marker + " ")
 		}
+
+		// 列表项内容使用悬挂缩进，折行后与首行文本对齐
+		indent := math.Max(r.pdf.GetX()-left, float64(r.fontSize)*1.5)
+		r.pushIndentBox(indent)
 	} else {
+		r.popBox()
+		if marker := r.taskListItemMarker(node); nil != marker && marker.TaskListItemChecked {
+			if r.TaskListCheckedGray {
+				r.popTextColor()
+			}
+			if r.TaskListCheckedStrike {
+				r.strike--
+			}
+		}
 		r.Newline()
 	}
 	return ast.WalkContinue
 }
 
+// taskListItemMarker 返回任务列表项 listItem 的 [ ] 标记节点，不是任务列表项的话返回 nil。
+func (r *PdfRenderer) taskListItemMarker(listItem *ast.Node) *ast.Node {
+	if 3 != listItem.ListData.Typ || nil == listItem.FirstChild || nil == listItem.FirstChild.FirstChild {
+		return nil
+	}
+	if marker := listItem.FirstChild.FirstChild; ast.NodeTaskListItemMarker == marker.Type {
+		return marker
+	}
+	return nil
+}
+
+// renderTaskListItemCheckbox 在当前位置绘制任务列表项的复选框，然后将横坐标移动到复选框之后。
+func (r *PdfRenderer) renderTaskListItemCheckbox(checked bool) {
+	size := float64(r.fontSize) * 0.85
+	x := r.pdf.GetX()
+	y := r.pdf.GetY() + float64(r.fontSize)*0.1
+	if r.TaskListFormField {
+		r.formFields = append(r.formFields, &formField{
+			page:    r.pdf.GetNumberOfPages(),
+			x:       x,
+			y:       y,
+			size:    size,
+			checked: checked,
+		})
+	} else if checked {
+		r.pdf.SetFillColor(66, 133, 244)
+		r.pdf.RectFromUpperLeftWithStyle(x, y, size, size, "F")
+		r.pdf.SetStrokeColor(255, 255, 255)
+		r.pdf.SetLineWidth(size / 8)
+		r.pdf.Line(x+size*0.22, y+size*0.52, x+size*0.42, y+size*0.72)
+		r.pdf.Line(x+size*0.42, y+size*0.72, x+size*0.78, y+size*0.3)
+		r.pdf.SetLineWidth(1)
+		r.pdf.SetStrokeColor(0, 0, 0)
+	} else {
+		r.pdf.SetStrokeColor(106, 115, 125)
+		r.pdf.RectFromUpperLeftWithStyle(x, y, size, size, "D")
+		r.pdf.SetStrokeColor(0, 0, 0)
+	}
+	r.pdf.SetX(x + size + float64(r.fontSize)/2)
+	r.LastOut = ' '
+}
+
 func (r *PdfRenderer) renderTaskListItemMarker(node *ast.Node, entering bool) ast.WalkStatus {
 	if entering {
-		var attrs [][]string
-		if node.TaskListItemChecked {
-			attrs = append(attrs, []string{"checked", ""})
+		// 复选框已经在列表项中绘制，绘制文本时跳过标记后的空格以免和复选框间距重复
+		if next := node.Next; nil != next && ast.NodeText == next.Type {
+			r.textOffsets[next] = len(next.Tokens) - len(bytes.TrimLeft(next.Tokens, " "))
 		}
-		attrs = append(attrs, []string{"disabled", ""}, []string{"type", "checkbox"})
-		//r.tag("input", attrs, true)
 	}
 	return ast.WalkContinue
 }
@@ -1002,7 +1310,7 @@ func (r *PdfRenderer) renderThematicBreak(node *ast.Node, entering bool) ast.Wal
 		r.Newline()
 		r.pdf.SetY(r.pdf.GetY() + 14)
 		r.pdf.SetStrokeColor(106, 115, 125)
-		r.pdf.Line(r.pdf.GetX()+float64(r.fontSize), r.pdf.GetY(), r.pageSize.W-r.margin-float64(r.fontSize), r.pdf.GetY())
+		r.pdf.Line(r.pdf.GetX()+float64(r.fontSize), r.pdf.GetY(), r.peekBox().right-float64(r.fontSize), r.pdf.GetY())
 		r.pdf.SetY(r.pdf.GetY() + 12)
 		r.pdf.SetStrokeColor(0, 0, 0)
 		r.Newline()
@@ -1062,13 +1370,58 @@ func (r *PdfRenderer) peekTextColor() *RGB {
 	return r.textColors[len(r.textColors)-1]
 }
 
-func (r *PdfRenderer) countParentContainerBlocks(n *ast.Node) (ret int) {
-	for parent := n.Parent; nil != parent; parent = parent.Parent {
-		if ast.NodeBlockquote == parent.Type || ast.NodeList == parent.Type {
-			ret++
-		}
+func (r *PdfRenderer) pushBox(box *Box) {
+	r.boxes = append(r.boxes, box)
+}
+
+func (r *PdfRenderer) popBox() *Box {
+	ret := r.boxes[len(r.boxes)-1]
+	r.boxes = r.boxes[:len(r.boxes)-1]
+	return ret
+}
+
+func (r *PdfRenderer) peekBox() *Box {
+	return r.boxes[len(r.boxes)-1]
+}
+
+// pushIndentBox 基于当前盒子缩进 indent 后压入一个新盒子，并将横坐标移动到新盒子左边界。
+func (r *PdfRenderer) pushIndentBox(indent float64) {
+	parent := r.peekBox()
+	r.pushBox(&Box{left: parent.left + indent, right: parent.right})
+	r.pdf.SetX(parent.left + indent)
+}
+
+// pushStyledBox 基于当前盒子压入一个带装饰样式 style 的新盒子，左边框和内边距占用新盒子的缩进，上内边距占用新盒子顶部的高度。
+func (r *PdfRenderer) pushStyledBox(style *PdfBoxStyle) {
+	parent := r.peekBox()
+	left := parent.left + style.BorderWidth + style.Padding
+	box := &Box{left: left, right: parent.right - style.Padding, style: style}
+	r.pushBox(box)
+	r.decorateBox(box)
+	r.pdf.SetY(r.pdf.GetY() + style.Padding)
+	r.pdf.SetX(left)
+}
+
+// popStyledBox 留出下内边距后弹出带装饰样式的盒子，此时才能确定其装饰在当前页上的高度。
+func (r *PdfRenderer) popStyledBox() {
+	box := r.peekBox()
+	r.pdf.SetY(r.pdf.GetY() + box.style.Padding)
+	r.popBox()
+	box.decoration.height = r.pdf.GetY() - box.decoration.y
+}
+
+// decorateBox 从当前纵坐标开始记录带装饰样式的盒子 box 在当前页上的装饰，装饰的高度在盒子结束或者换页时确定，保存时绘制在页面内容之前。
+func (r *PdfRenderer) decorateBox(box *Box) {
+	style := box.style
+	x := box.left - style.Padding - style.BorderWidth
+	box.decoration = &boxDecoration{
+		page:  r.pdf.GetNumberOfPages(),
+		x:     x,
+		y:     r.pdf.GetY(),
+		width: box.right + style.Padding - x,
+		style: style,
 	}
-	return
+	r.decorations = append(r.decorations, box.decoration)
 }
 
 // WriteByte 输出一个字节 c。
@@ -1089,9 +1442,8 @@ func (r *PdfRenderer) WriteString(content string) {
 	if length := len(content); 0 < length {
 		buf := bytes.Buffer{}
 		x := r.pdf.GetX()
-		startX := x
 		runes := []rune(content)
-		pageRight := r.pageSize.W - r.margin
+		pageRight := r.peekBox().right
 		font := r.peekFont()
 		if nil != font {
 			r.pdf.SetFont(font.family, font.style, font.size)
@@ -1108,13 +1460,12 @@ func (r *PdfRenderer) WriteString(content string) {
 
 			if '\n' == c {
 				if 0 < buf.Len() {
-					r.pdf.Cell(nil, buf.String())
+					r.cell(buf.String())
 					buf.Reset()
 				}
 
-				r.pdf.Br(float64(r.fontSize) + 2)
-				r.pdf.SetX(startX)
-				x = startX
+				r.br(float64(r.fontSize) + 2)
+				x = r.pdf.GetX()
 				continue
 			}
 
@@ -1123,11 +1474,10 @@ func (r *PdfRenderer) WriteString(content string) {
 				nextC := runes[i+1]
 				nextWidth, _ := r.pdf.MeasureTextWidth(string(nextC))
 				if x+width+nextWidth > pageRight {
-					r.pdf.Cell(nil, buf.String())
+					r.cell(buf.String())
 					buf.Reset()
-					r.pdf.Br(float64(r.fontSize) + 2)
+					r.br(float64(r.fontSize) + 2)
 					x = r.pdf.GetX()
-					continue
 				}
 			}
 
@@ -1138,125 +1488,176 @@ func (r *PdfRenderer) WriteString(content string) {
 			if r.pdf.GetY() > r.pageSize.H-r.margin*2 {
 				r.addPage()
 			}
-			r.pdf.Cell(nil, buf.String())
+			r.cell(buf.String())
 		}
 
 		r.LastOut = content[length-1]
 	}
 }
 
-// Newline 会在最新内容不是换行符 \n 时输出一个换行符。
+// cell 在当前位置输出一段不换行的文本 text，需要的话为其绘制删除线。
+func (r *PdfRenderer) cell(text string) {
+	x := r.pdf.GetX()
+	r.pdf.Cell(nil, text)
+	if 0 < r.strike {
+		y := r.pdf.GetY() + float64(r.peekFont().size)/2
+		textColor := r.peekTextColor()
+		r.pdf.SetStrokeColor(textColor.R, textColor.G, textColor.B)
+		r.pdf.Line(x, y, r.pdf.GetX(), y)
+		r.pdf.SetStrokeColor(0, 0, 0)
+	}
+}
+
+// Newline 会在最新内容不是换行符 \n 时输出一个换行符，并将横坐标移动到当前盒子左边界。
 func (r *PdfRenderer) Newline() {
 	if lex.ItemNewline != r.LastOut {
 		r.pdf.Br(r.lineHeight)
 		r.LastOut = lex.ItemNewline
 	}
+	r.pdf.SetX(r.peekBox().left)
 }
 
-func (r *PdfRenderer) downloadImg(src string) (localPath string, ok, isTemp bool) {
-	if strings.HasPrefix(src, "//") {
-		src = "https:" + src
-	}
+// br 换行 h 高度，并将横坐标移动到当前盒子左边界。
+func (r *PdfRenderer) br(h float64) {
+	r.pdf.Br(h)
+	r.pdf.SetX(r.peekBox().left)
+}
 
-	u, err := url.Parse(src)
-	if nil != err {
-		logger.Infof("image src [%s] is not an valid URL, treat it as local path", src)
-		return src, true, false
+// loadImg 通过 ImageResolver 加载图片 src，返回图片数据的键，用于 getImgSize 和 drawImg，加载失败时 ok 为 false。
+func (r *PdfRenderer) loadImg(src string) (key string, ok bool) {
+	key = imgKey(src)
+	if data, loaded := r.imgData[key]; loaded {
+		return key, nil != data
 	}
 
-	if !strings.HasPrefix(u.Scheme, "http") {
-		logger.Infof("image src [%s] scheme is not [http] or [https], treat it as local path", src)
-		return src, true, false
+	data, err := r.ImageResolver.ResolveImage(src)
+	if nil != err {
+		logger.Warnf("load image [%s] failed: %s", shortImgSrc(src), err)
+		r.addImgFailure(src, err)
+		data = nil
 	}
+	r.imgData[key] = data
+	return key, nil != data
+}
 
-	src = r.qiniuImgProcessing(src)
-	u, _ = url.Parse(src)
-
-	client := http.Client{
-		Timeout: 5 * time.Second,
-	}
-	req := &http.Request{
-		Header: http.Header{
-			"User-Agent": []string{"Lute-PDF; +https://github.com/88250/lute-pdf"},
-		},
-		URL: u,
+// getImgSize 返回键为 imgPath 的图片的自然尺寸，embeddedDPI 为 true 且图片记录了分辨率时按照记录的分辨率换算，否则按照 ImageDPI 换算。
+//
+// SVG 图片按照其声明的尺寸换算，无法直接嵌入的格式会重新编码为 PNG，记录了 EXIF 方向的 JPEG 图片会转为正向并返回转正后的尺寸，格式不支持时返回的错误包装了 image.ErrFormat。
+func (r *PdfRenderer) getImgSize(imgPath string, embeddedDPI bool) (width, height float64, err error) {
+	data := r.imgData[imgPath]
+	if isSVG(data) {
+		svg, err := r.loadSVG(imgPath, data)
+		if nil != err {
+			return 0, 0, err
+		}
+		return svg.width, svg.height, nil
 	}
-	resp, err := client.Do(req)
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
 	if nil != err {
-		logger.Warnf("download image [%s] failed: %s", src, err)
-		return src, false, false
+		return 0, 0, fmt.Errorf("decode image [%s] failed: %w", imgPath, err)
 	}
-	defer resp.Body.Close()
-	if 200 != resp.StatusCode {
-		logger.Warnf("download image [%s] failed, status code is [%d]", src, resp.StatusCode)
-		return src, false, false
+	orientation := jpegOrientation(data)
+	if _, ok := r.imgs[imgPath]; !ok {
+		if 1 < orientation {
+			if r.imgs[imgPath], err = orientImg(data, orientation); nil != err {
+				return 0, 0, fmt.Errorf("orient image [%s] failed: %w", imgPath, err)
+			}
+		} else if !embeddableImg(data, format) {
+			if r.imgs[imgPath], err = reencodeImg(data); nil != err {
+				return 0, 0, fmt.Errorf("reencode %s image [%s] failed: %w", format, imgPath, err)
+			}
+		}
 	}
 
-	data, err := ioutil.ReadAll(resp.Body)
-	file, err := ioutil.TempFile("", "lute-pdf.img.")
-	if nil != err {
-		logger.Warnf("create temp image [%s] failed: %s", src, err)
-		return src, false, false
+	var dpiX, dpiY float64
+	if embeddedDPI {
+		dpiX, dpiY = imgDPI(data)
 	}
-	_, err = file.Write(data)
-	if nil != err {
-		logger.Warnf("write temp image [%s] failed: %s", src, err)
-		return src, false, false
+	if 1 > dpiX || 1 > dpiY {
+		dpiX, dpiY = r.ImageDPI, r.ImageDPI
 	}
-	file.Close()
-	return file.Name(), true, true
+	if 5 <= orientation {
+		// 旋转 90° 后宽高互换
+		config.Width, config.Height = config.Height, config.Width
+		dpiX, dpiY = dpiY, dpiX
+	}
+	return float64(config.Width) * 72 / dpiX, float64(config.Height) * 72 / dpiY, nil
 }
 
-// qiniuImgProcessing 七牛云图片样式处理。
-func (r *PdfRenderer) qiniuImgProcessing(src string) string {
-	if !strings.Contains(src, "img.hacpai.com") && !strings.Contains(src, "b3logfile.com") && !strings.Contains(src, "imageView") {
-		return src
+// drawImg 在 (x, y) 处按照 width × height 绘制键为 imgPath 的图片，需要先通过 getImgSize 获取尺寸。
+func (r *PdfRenderer) drawImg(imgPath string, x, y, width, height float64) {
+	if svg := r.svgs[imgPath]; nil != svg {
+		r.drawSVG(svg, x, y, width, height)
+		return
 	}
-
-	if 0 < strings.Index(src, "?") {
-		src = src[:strings.Index(src, "?")]
+	data := r.imgs[imgPath]
+	if nil == data {
+		data = r.imgData[imgPath]
 	}
-
-	maxWidth := int(math.Round(r.pageSize.W-r.margin*2) * 128 / 72)
-	style := "imageView2/2/w/%d/interlace/1/format/jpg"
-	style = fmt.Sprintf(style, maxWidth)
-	src += "?" + style
-	return src
-}
-
-func (r *PdfRenderer) getImgSize(imgPath string) (width, height float64) {
-	file, err := os.Open(imgPath)
-	if nil != err {
-		logger.Fatal(err)
+	holder, err := gopdf.ImageHolderByBytes(r.optimizeImg(data, width))
+	if nil == err {
+		err = r.pdf.ImageByHolder(holder, x, y, &gopdf.Rect{W: width, H: height})
 	}
-	img, _, err := image.Decode(file)
 	if nil != err {
-		logger.Fatal(err)
+		logger.Warnf("draw image [%s] failed: %s", imgPath, err)
 	}
-	file.Close()
+}
 
-	imageRect := img.Bounds()
-	k := 1
-	w := -128
-	h := -128
-	if w < 0 {
-		w = -imageRect.Dx() * 72 / w / k
-	}
-	if h < 0 {
-		h = -imageRect.Dy() * 72 / h / k
+// drawImgPlaceholder 在 (x, y) 处绘制 width × height 的灰色占位框代替无法显示的图片 src，框内居中输出替代文本 alt 和图片地址。
+func (r *PdfRenderer) drawImgPlaceholder(alt, src string, x, y, width, height float64) {
+	r.pdf.SetFillColor(248, 248, 248)
+	r.pdf.SetStrokeColor(200, 200, 200)
+	r.pdf.SetLineWidth(0.5)
+	r.pdf.RectFromUpperLeftWithStyle(x, y, width, height, "FD")
+	r.pdf.SetLineWidth(1)
+	r.pdf.SetStrokeColor(0, 0, 0)
+
+	font := r.peekFont()
+	r.pdf.SetFont(font.family, font.style, font.size)
+	var lines []string
+	for _, text := range []string{alt, shortImgSrc(src)} {
+		if "" == text {
+			continue
+		}
+		// 每项只输出一行，放不下的部分截断
+		if split, err := r.pdf.SplitText(text, width-8); nil == err && 0 < len(split) {
+			text = split[0]
+		}
+		lines = append(lines, text)
 	}
-	if w == 0 {
-		w = h * imageRect.Dx() / imageRect.Dy()
+	if height < float64(len(lines))*r.lineHeight {
+		lines = lines[:1]
 	}
-	if h == 0 {
-		h = w * imageRect.Dy() / imageRect.Dx()
+
+	r.pdf.SetTextColor(150, 150, 150)
+	top := y + (height-float64(len(lines))*r.lineHeight)/2
+	for i, line := range lines {
+		textWidth, _ := r.pdf.MeasureTextWidth(line)
+		r.pdf.SetX(x + math.Max(4, (width-textWidth)/2))
+		r.pdf.SetY(top + float64(i)*r.lineHeight)
+		r.pdf.Cell(nil, line)
 	}
-	return float64(w), float64(h)
+	textColor := r.peekTextColor()
+	r.pdf.SetTextColor(textColor.R, textColor.G, textColor.B)
+	r.pdf.SetY(y)
 }
 
 func (r *PdfRenderer) addPage() {
+	// 跨页的盒子的装饰在当前页上结束，然后在新页上从顶部继续
+	bottom := r.pdf.GetY()
+	for _, box := range r.boxes {
+		if nil != box.decoration {
+			box.decoration.height = bottom - box.decoration.y
+		}
+	}
 	r.renderFooter()
 	r.pdf.AddPage()
+	for _, box := range r.boxes {
+		if nil != box.decoration {
+			r.decorateBox(box)
+		}
+	}
+	r.pdf.SetX(r.peekBox().left)
 }
 
 func (r *PdfRenderer) renderFooter() {
@@ -1290,6 +1691,14 @@ type Font struct {
 	size   int
 }
 
+// Box 描述了块级盒子，盒子内的文本折行时会回到盒子的左边界。
+type Box struct {
+	left       float64        // 左边界
+	right      float64        // 右边界
+	style      *PdfBoxStyle   // 装饰样式，为空时不绘制装饰
+	decoration *boxDecoration // 在当前页上的装饰
+}
+
 type RGB struct {
 	R, G, B uint8
 }
@@ -0,0 +1,54 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+)
+
+func TestRenderTaskListItemMarker(t *testing.T) {
+	tests := []struct {
+		md   string
+		want string // 绘制的文本
+	}{
+		{"- [x] done", "done"},
+		{"- [ ]   todo", "todo"},
+		{"- [x] **bold**", ""},
+	}
+	for _, test := range tests {
+		tree := parse.Parse("", []byte(test.md), parse.NewOptions())
+		before := dumpTree(tree.Root)
+		r := &PdfRenderer{textOffsets: map[*ast.Node]int{}}
+		var text *ast.Node
+		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if entering && ast.NodeTaskListItemMarker == n.Type {
+				r.renderTaskListItemMarker(n, true)
+				text = n.Next
+			}
+			return ast.WalkContinue
+		})
+		if after := dumpTree(tree.Root); before != after {
+			t.Errorf("renderTaskListItemMarker(%q) modified tree:\n%s\nwant:\n%s", test.md, after, before)
+		}
+		if nil == text || ast.NodeText != text.Type {
+			if "" != test.want {
+				t.Errorf("renderTaskListItemMarker(%q) found no text", test.md)
+			}
+			continue
+		}
+		if got := string(text.Tokens[r.textOffsets[text]:]); test.want != got {
+			t.Errorf("renderTaskListItemMarker(%q) draws %q, want %q", test.md, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ImageResolver 描述了图片加载器，用于从不同的来源（文件系统、内存、压缩包、自定义协议等）加载图片数据。
+type ImageResolver interface {
+	// ResolveImage 返回图片地址 src 对应的图片数据，不处理该地址时返回 ErrImageNotResolved。
+	ResolveImage(src string) ([]byte, error)
+}
+
+// ErrImageNotResolved 表示图片加载器不处理该图片地址或者找不到该图片，ImageResolvers 会继续尝试下一个加载器。
+var ErrImageNotResolved = errors.New("image not resolved")
+
+// ImageResolverFunc 将函数适配为图片加载器。
+type ImageResolverFunc func(src string) ([]byte, error)
+
+func (f ImageResolverFunc) ResolveImage(src string) ([]byte, error) {
+	return f(src)
+}
+
+// ImageResolvers 按顺序尝试多个图片加载器，直到某个加载器处理了该图片地址。
+//
+// 比如 ImageResolvers{bundle, renderer} 先从资源包中查找，找不到时使用默认的加载方式。
+type ImageResolvers []ImageResolver
+
+func (resolvers ImageResolvers) ResolveImage(src string) ([]byte, error) {
+	for _, resolver := range resolvers {
+		data, err := resolver.ResolveImage(src)
+		if !errors.Is(err, ErrImageNotResolved) {
+			return data, err
+		}
+	}
+	return nil, ErrImageNotResolved
+}
+
+// MapImageResolver 从内存中加载图片，键为图片地址，值为图片数据。
+type MapImageResolver map[string][]byte
+
+func (m MapImageResolver) ResolveImage(src string) ([]byte, error) {
+	if data, ok := m[src]; ok {
+		return data, nil
+	}
+	return nil, ErrImageNotResolved
+}
+
+// FSImageResolver 从文件系统 FS 中加载图片。
+//
+// Prefix 为空时只处理相对路径，否则只处理以 Prefix 开头的地址，比如 asset:// 或者 siyuan://，去掉前缀后的部分作为路径。
+type FSImageResolver struct {
+	FS     fs.FS  // 文件系统，比如 os.DirFS、embed.FS 或者 zip.Reader
+	Prefix string // 地址前缀
+}
+
+func (resolver *FSImageResolver) ResolveImage(src string) ([]byte, error) {
+	name := src
+	if "" != resolver.Prefix {
+		if !strings.HasPrefix(src, resolver.Prefix) {
+			return nil, ErrImageNotResolved
+		}
+		name = strings.TrimPrefix(src, resolver.Prefix)
+	} else if strings.Contains(src, "://") || strings.HasPrefix(src, "/") || strings.HasPrefix(strings.ToLower(src), "data:") {
+		return nil, ErrImageNotResolved
+	}
+
+	names := []string{name}
+	if unescaped, err := url.PathUnescape(name); nil == err && unescaped != name {
+		names = append(names, unescaped)
+	}
+	for _, name := range names {
+		// fs.FS 中的路径不能以 / 开头，也不能包含 ..
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+		if data, err := fs.ReadFile(resolver.FS, name); nil == err {
+			return data, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, ErrImageNotResolved
+}
+
+// NewZipImageResolver 读取压缩包 zipPath 并返回从中加载图片的加载器，地址前缀 prefix 的含义同 FSImageResolver。
+func NewZipImageResolver(zipPath, prefix string) (*FSImageResolver, error) {
+	data, err := ioutil.ReadFile(zipPath)
+	if nil != err {
+		return nil, err
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if nil != err {
+		return nil, err
+	}
+	return &FSImageResolver{FS: reader, Prefix: prefix}, nil
+}
+
+// ResolveImage 是默认的图片加载方式：解码 data: URI，下载网络图片（使用缓存），其他地址按照 ImageRoot 作为本地路径读取。
+func (r *PdfRenderer) ResolveImage(src string) ([]byte, error) {
+	if strings.HasPrefix(strings.ToLower(src), "data:") {
+		return decodeDataURI(src)
+	}
+
+	link, remote := r.remoteImgLink(src)
+	if !remote {
+		localPath, err := r.localImgPath(src)
+		if nil != err {
+			return nil, err
+		}
+		return ioutil.ReadFile(localPath)
+	}
+
+	download := r.fetchImg(link)
+	if nil != download.err {
+		return nil, download.err
+	}
+	return ioutil.ReadFile(download.path)
+}
+
+// usesDefaultResolver 判断图片加载器是否会用到默认的加载方式，只有这时才需要预下载网络图片。
+func (r *PdfRenderer) usesDefaultResolver() bool {
+	switch resolver := r.ImageResolver.(type) {
+	case *PdfRenderer:
+		return r == resolver
+	case ImageResolvers:
+		for _, res := range resolver {
+			if res == ImageResolver(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PdfImageRewriteRule 描述了网络图片地址的改写规则，一般用于让图床按照页面宽度返回缩略图。
+//
+// 模板中可以使用 {url}（原地址）、{urlNoQuery}（去掉查询参数的原地址）、{maxWidthPx}（内容宽度按照 ImageDPI 换算的像素数）以及 Pattern 的分组 $1、${name}。
+type PdfImageRewriteRule struct {
+	Hosts    []string       // 匹配的主机名，同时匹配其子域名，为空时不限制
+	Pattern  *regexp.Regexp // 匹配完整地址的正则表达式，为空时不限制
+	Template string         // 改写后的地址模板
+}
+
+// NewQiniuImageRewriteRules 创建七牛云图片处理的预设规则，将链滴和 B3log 图床的图片缩放到内容宽度并转换为渐进式 JPEG。
+func NewQiniuImageRewriteRules() []*PdfImageRewriteRule {
+	return []*PdfImageRewriteRule{
+		{
+			Pattern:  regexp.MustCompile(`img\.hacpai\.com|b3logfile\.com|imageView`),
+			Template: "{urlNoQuery}?imageView2/2/w/{maxWidthPx}/interlace/1/format/jpg",
+		},
+	}
+}
+
+// match 判断地址 link 是否匹配规则。
+func (rule *PdfImageRewriteRule) match(link string) bool {
+	if 0 < len(rule.Hosts) {
+		u, err := url.Parse(link)
+		if nil != err {
+			return false
+		}
+		host := strings.ToLower(u.Hostname())
+		matched := false
+		for _, h := range rule.Hosts {
+			h = strings.ToLower(strings.TrimPrefix(h, "."))
+			if host == h || strings.HasSuffix(host, "."+h) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return nil == rule.Pattern || rule.Pattern.MatchString(link)
+}
+
+// rewriteImgLink 使用第一条匹配的 ImageRewriteRules 改写网络图片地址 link，没有匹配的规则时原样返回。
+func (r *PdfRenderer) rewriteImgLink(link string) string {
+	for _, rule := range r.ImageRewriteRules {
+		if !rule.match(link) {
+			continue
+		}
+
+		ret := rule.Template
+		if nil != rule.Pattern {
+			if groups := rule.Pattern.FindStringSubmatchIndex(link); nil != groups {
+				ret = string(rule.Pattern.ExpandString(nil, ret, link, groups))
+			}
+		}
+		urlNoQuery := link
+		if i := strings.IndexAny(urlNoQuery, "?#"); 0 <= i {
+			urlNoQuery = urlNoQuery[:i]
+		}
+		maxWidthPx := int(math.Round((r.pageSize.W - r.margin*2) * r.ImageDPI / 72))
+		return strings.NewReplacer(
+			"{url}", link,
+			"{urlNoQuery}", urlNoQuery,
+			"{maxWidthPx}", strconv.Itoa(maxWidthPx),
+		).Replace(ret)
+	}
+	return link
+}
@@ -0,0 +1,124 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/signintech/gopdf"
+)
+
+func TestRewriteImgLink(t *testing.T) {
+	rules := []*PdfImageRewriteRule{
+		{Hosts: []string{"cdn.example.com"}, Pattern: regexp.MustCompile(`\.gif$`), Template: "{url}#gif"},
+		{Hosts: []string{"cdn.example.com"}, Template: "{urlNoQuery}?w={maxWidthPx}"},
+		{Hosts: []string{".Example.org"}, Template: "{url}#org"},
+		{Pattern: regexp.MustCompile(`^https://img\.example\.net/(?P<path>.+)$`), Template: "https://resize.example.net/{maxWidthPx}/${path}"},
+		{Pattern: regexp.MustCompile(`img\.example\.net`), Template: "{url}#unanchored"},
+		{Pattern: regexp.MustCompile(`^https?://`), Template: "{url}#any"},
+	}
+
+	tests := []struct {
+		link string
+		want string
+	}{
+		// 按照顺序使用第一条匹配的规则
+		{"https://cdn.example.com/a.gif", "https://cdn.example.com/a.gif#gif"},
+		{"https://cdn.example.com/a.png?x=1#y", "https://cdn.example.com/a.png?w=800"},
+		{"https://cdn.example.com/a.gif?x=1", "https://cdn.example.com/a.gif?w=800"},
+		{"https://img.example.net/a/b.png", "https://resize.example.net/800/a/b.png"},
+
+		// hosts 匹配主机名和子域名，不区分大小写，忽略端口
+		{"https://CDN.Example.com:8443/a.png", "https://CDN.Example.com:8443/a.png?w=800"},
+		{"https://example.org/a.png", "https://example.org/a.png#org"},
+		{"https://a.b.example.org/a.png", "https://a.b.example.org/a.png#org"},
+		{"https://notexample.org/a.png", "https://notexample.org/a.png#any"},
+		{"https://example.org.evil.com/a.png", "https://example.org.evil.com/a.png#any"},
+		{"https://evil.com/cdn.example.com/a.png", "https://evil.com/cdn.example.com/a.png#any"},
+		{"https://sub.cdn.example.com/a.png", "https://sub.cdn.example.com/a.png?w=800"},
+
+		// pattern 匹配完整地址，需要锚定时由规则自己使用 ^ 和 $
+		{"http://img.example.net/a.png", "http://img.example.net/a.png#unanchored"},
+		{"https://evil.com/?u=https://img.example.net/a.png", "https://evil.com/?u=https://img.example.net/a.png#unanchored"},
+		{"ftp://img.example.net/a.png", "ftp://img.example.net/a.png#unanchored"},
+
+		// 没有匹配的规则时原样返回
+		{"ftp://example.com/a.png", "ftp://example.com/a.png"},
+	}
+	r := &PdfRenderer{ImageRewriteRules: rules, pageSize: &gopdf.Rect{W: 500, H: 800}, margin: 50, ImageDPI: 144}
+	for _, test := range tests {
+		if got := r.rewriteImgLink(test.link); test.want != got {
+			t.Errorf("rewriteImgLink(%q) = %q, want %q", test.link, got, test.want)
+		}
+	}
+}
+
+func TestQiniuImageRewriteRules(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://b3logfile.com/file/a.png", "https://b3logfile.com/file/a.png?imageView2/2/w/800/interlace/1/format/jpg"},
+		{"https://img.hacpai.com/a.png?imageView2/2/w/100", "https://img.hacpai.com/a.png?imageView2/2/w/800/interlace/1/format/jpg"},
+		{"https://cdn.example.com/a.png?imageView2/2/w/100", "https://cdn.example.com/a.png?imageView2/2/w/800/interlace/1/format/jpg"},
+		{"https://img-hacpai.com/a.png", "https://img-hacpai.com/a.png"},
+		{"https://cdn.example.com/a.png", "https://cdn.example.com/a.png"},
+	}
+	r := &PdfRenderer{ImageRewriteRules: NewQiniuImageRewriteRules(), pageSize: &gopdf.Rect{W: 500, H: 800}, margin: 50, ImageDPI: 144}
+	for _, test := range tests {
+		if got := r.rewriteImgLink(test.link); test.want != got {
+			t.Errorf("rewriteImgLink(%q) = %q, want %q", test.link, got, test.want)
+		}
+	}
+
+	// 没有规则时不改写
+	r.ImageRewriteRules = nil
+	if link := "https://b3logfile.com/file/a.png"; link != r.rewriteImgLink(link) {
+		t.Errorf("rewriteImgLink(%q) without rules = %q", link, r.rewriteImgLink(link))
+	}
+}
+
+func TestLoadImageRewriteRules(t *testing.T) {
+	confPath := filepath.Join(t.TempDir(), "rewrite.json")
+	conf := `[
+  {"hosts": ["cdn.example.com"], "template": "{url}#1"},
+  {"preset": "QINIU"},
+  {"pattern": "^https://img\\.example\\.org/(.+)$", "template": "https://resize.example.org/$1"}
+]`
+	if err := ioutil.WriteFile(confPath, []byte(conf), 0644); nil != err {
+		t.Fatal(err)
+	}
+
+	rules := loadImageRewriteRules(confPath)
+	if 3 != len(rules) {
+		t.Fatalf("loadImageRewriteRules() = %d rules, want 3", len(rules))
+	}
+	if "{url}#1" != rules[0].Template || 1 != len(rules[0].Hosts) || nil != rules[0].Pattern {
+		t.Errorf("rules[0] = %+v, want hosts rule", rules[0])
+	}
+	if NewQiniuImageRewriteRules()[0].Template != rules[1].Template {
+		t.Errorf("rules[1] = %+v, want qiniu preset", rules[1])
+	}
+	if nil == rules[2].Pattern || `^https://img\.example\.org/(.+)$` != rules[2].Pattern.String() {
+		t.Errorf("rules[2] = %+v, want pattern rule", rules[2])
+	}
+
+	// 配置为空数组时不改写
+	if err := ioutil.WriteFile(confPath, []byte("[]"), 0644); nil != err {
+		t.Fatal(err)
+	}
+	if rules = loadImageRewriteRules(confPath); 0 != len(rules) {
+		t.Errorf("loadImageRewriteRules([]) = %d rules, want 0", len(rules))
+	}
+}
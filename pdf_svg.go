@@ -0,0 +1,896 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/signintech/gopdf"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+// svgImage 描述了一张 SVG 图片，支持的内容转换为 PDF 矢量绘图，否则栅格化。
+type svgImage struct {
+	width  float64       // 自然宽度（pt）
+	height float64       // 自然高度（pt）
+	doc    []byte        // 矢量绘图生成的单页 PDF，导入为模板后绘制，导入失败时置为 nil
+	texts  []*svgText    // 文本，使用文档字体绘制在矢量图形之上
+	raster []byte        // 无法转换为矢量绘图时栅格化的 PNG 数据
+	stream io.ReadSeeker // 导入模板时使用的数据流，导入器按照其地址区分来源，所以需要一直持有
+	tpl    int           // 导入后的模板编号，小于 0 时尚未导入
+}
+
+// svgText 描述了 SVG 图片中的一段文本，坐标相对于图片左上角（pt）。
+type svgText struct {
+	x, y   float64 // 基线起点
+	size   float64 // 字号
+	text   string  // 文本
+	anchor string  // 对齐方式：start、middle、end
+	bold   bool    // 是否为粗体
+	color  *RGB    // 颜色
+}
+
+// svgRasterDPI 为 SVG 栅格化时的分辨率。
+const svgRasterDPI = 192
+
+// errSVGUnsupported 表示 SVG 图片中包含无法转换为矢量绘图的内容，比如滤镜、蒙版和嵌入的位图。
+var errSVGUnsupported = errors.New("unsupported svg feature")
+
+// loadSVG 加载路径为 imgPath 的 SVG 图片数据 data，无法转换为矢量绘图时栅格化。
+func (r *PdfRenderer) loadSVG(imgPath string, data []byte) (*svgImage, error) {
+	if ret := r.svgs[imgPath]; nil != ret {
+		return ret, nil
+	}
+
+	root, err := parseSVGTree(data)
+	if nil != err {
+		return nil, fmt.Errorf("parse svg [%s] failed: %w", imgPath, err)
+	}
+	c := newSVGConverter(root)
+	ret := &svgImage{width: c.width, height: c.height, tpl: -1}
+	if err = c.convert(); nil == err {
+		ret.doc, ret.texts = c.pdf(), c.texts
+	} else {
+		logger.Infof("svg [%s] contains %s, rasterize it", imgPath, err)
+		if ret.raster, err = rasterizeSVG(data, ret.width, ret.height); nil != err {
+			return nil, fmt.Errorf("rasterize svg [%s] failed: %w", imgPath, err)
+		}
+	}
+	r.svgs[imgPath] = ret
+	return ret, nil
+}
+
+// drawSVG 在 (x, y) 处按照 width × height 绘制 SVG 图片 svg。
+func (r *PdfRenderer) drawSVG(svg *svgImage, x, y, width, height float64) {
+	if nil != svg.raster {
+		holder, err := gopdf.ImageHolderByBytes(svg.raster)
+		if nil == err {
+			err = r.pdf.ImageByHolder(holder, x, y, &gopdf.Rect{W: width, H: height})
+		}
+		if nil != err {
+			logger.Warnf("draw rasterized svg failed: %s", err)
+		}
+		return
+	}
+
+	if 0 > svg.tpl {
+		if nil == svg.doc {
+			return
+		}
+		if err := r.importSVG(svg); nil != err {
+			logger.Warnf("import svg failed: %s", err)
+			svg.doc = nil
+			return
+		}
+	}
+	r.pdf.UseImportedTemplate(svg.tpl, x, y, width, height)
+
+	if 0 == len(svg.texts) {
+		return
+	}
+	curX, curY := r.pdf.GetX(), r.pdf.GetY()
+	scaleX, scaleY := width/svg.width, height/svg.height
+	for _, text := range svg.texts {
+		family, style := "regular", "R"
+		if text.bold {
+			family, style = "bold", "B"
+		}
+		r.pdf.SetFont(family, style, text.size*scaleY)
+		textX := x + text.x*scaleX
+		if "start" != text.anchor {
+			textWidth, _ := r.pdf.MeasureTextWidth(text.text)
+			if "middle" == text.anchor {
+				textX -= textWidth / 2
+			} else if "end" == text.anchor {
+				textX -= textWidth
+			}
+		}
+		r.pdf.SetTextColor(text.color.R, text.color.G, text.color.B)
+		r.pdf.SetX(textX)
+		r.pdf.SetY(y + text.y*scaleY)
+		r.pdf.Text(text.text)
+	}
+	font, textColor := r.peekFont(), r.peekTextColor()
+	r.pdf.SetFont(font.family, font.style, font.size)
+	r.pdf.SetTextColor(textColor.R, textColor.G, textColor.B)
+	r.pdf.SetX(curX)
+	r.pdf.SetY(curY)
+}
+
+// importSVG 将 SVG 图片 svg 的矢量绘图导入为模板。
+func (r *PdfRenderer) importSVG(svg *svgImage) (err error) {
+	defer func() {
+		// 导入器遇到错误时会 panic
+		if e := recover(); nil != e {
+			err = fmt.Errorf("%v", e)
+		}
+	}()
+
+	svg.stream = bytes.NewReader(svg.doc)
+	svg.tpl = r.pdf.ImportPageStream(&svg.stream, 1, "/MediaBox")
+	return
+}
+
+// rasterizeSVG 将 SVG 图片数据 data 按照 svgRasterDPI 栅格化为白色背景的 PNG 图片，width、height 为自然尺寸（pt）。
+func rasterizeSVG(data []byte, width, height float64) ([]byte, error) {
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data), oksvg.IgnoreErrorMode)
+	if nil != err {
+		return nil, err
+	}
+
+	scale := svgRasterDPI / 72.0
+	if maxSide := 4096.0; width*scale > maxSide || height*scale > maxSide {
+		scale = maxSide / math.Max(width, height)
+	}
+	w, h := int(math.Ceil(width*scale)), int(math.Ceil(height*scale))
+	if 1 > w || 1 > h {
+		return nil, errors.New("empty svg")
+	}
+	icon.SetTarget(0, 0, float64(w), float64(h))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	icon.Draw(rasterx.NewDasher(w, h, rasterx.NewScannerGV(w, h, img, img.Bounds())), 1)
+
+	buf := bytes.Buffer{}
+	if err = png.Encode(&buf, img); nil != err {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// svgConverter 用于将 SVG 元素树转换为 PDF 内容流。
+type svgConverter struct {
+	root        *svgNode
+	ids         map[string]*svgNode // 带 id 的元素，用于渐变、剪切路径和 <use> 引用
+	width       float64             // 图片宽度（pt）
+	height      float64             // 图片高度（pt）
+	viewport    [2]float64          // 视口宽高（用户单位），用于计算百分比长度
+	base        svgMatrix           // 用户坐标到 PDF 页面坐标的变换
+	content     bytes.Buffer        // 内容流
+	gstates     []string            // 透明度图形状态
+	gstateNames map[string]string   // 透明度图形状态名，键为状态字典内容
+	patterns    []string            // 渐变填充图案
+	texts       []*svgText          // 文本
+}
+
+// svgStyle 描述了元素的绘制样式。
+type svgStyle struct {
+	fill          string
+	stroke        string
+	fillOpacity   float64
+	strokeOpacity float64
+	opacity       float64
+	strokeWidth   float64
+	fillRule      string
+	lineCap       string
+	lineJoin      string
+	miterLimit    float64
+	dash          string
+	fontSize      float64
+	fontWeight    string
+	textAnchor    string
+	color         string
+	visibility    string
+}
+
+func newSVGConverter(root *svgNode) *svgConverter {
+	ret := &svgConverter{root: root, ids: map[string]*svgNode{}, gstateNames: map[string]string{}}
+	root.walk(func(n *svgNode) {
+		if id := n.attrs["id"]; "" != n.name && "" != id {
+			ret.ids[id] = n
+		}
+	})
+
+	var vbX, vbY, vbW, vbH float64
+	if viewBox := svgNumbers(root.attrs["viewBox"]); 4 == len(viewBox) && 0 < viewBox[2] && 0 < viewBox[3] {
+		vbX, vbY, vbW, vbH = viewBox[0], viewBox[1], viewBox[2], viewBox[3]
+	}
+	// 百分比尺寸没有参照，和缺失一样使用 viewBox 的尺寸
+	length := func(value string, defaultValue float64) float64 {
+		if strings.HasSuffix(value, "%") {
+			return defaultValue
+		}
+		return svgLength(value, 0, 16, defaultValue)
+	}
+	w, h := length(root.attrs["width"], 0), length(root.attrs["height"], 0)
+	switch {
+	case 0 < w && 0 >= h && 0 < vbW:
+		h = w * vbH / vbW
+	case 0 >= w && 0 < h && 0 < vbH:
+		w = h * vbW / vbH
+	case 0 >= w && 0 >= h:
+		w, h = vbW, vbH
+	}
+	if 0 >= w || 0 >= h {
+		// 浏览器中替换元素的默认尺寸
+		w, h = 300, 150
+	}
+	if 0 >= vbW || 0 >= vbH {
+		vbW, vbH = w, h
+	}
+	ret.viewport = [2]float64{vbW, vbH}
+	// CSS 像素为 1/96 英寸，即 0.75pt
+	ret.width, ret.height = w*0.75, h*0.75
+
+	// preserveAspectRatio 默认为 xMidYMid meet
+	scaleX, scaleY := w/vbW, h/vbH
+	translateX, translateY := -vbX*scaleX, -vbY*scaleY
+	if par := strings.Fields(root.attrs["preserveAspectRatio"]); 0 == len(par) || "none" != par[0] {
+		align := "xMidYMid"
+		if 0 < len(par) {
+			align = par[0]
+		}
+		scale := math.Min(scaleX, scaleY)
+		if 1 < len(par) && "slice" == par[1] {
+			scale = math.Max(scaleX, scaleY)
+		}
+		factor := func(min, mid string) float64 {
+			switch {
+			case strings.Contains(align, min):
+				return 0
+			case strings.Contains(align, mid):
+				return 0.5
+			}
+			return 1
+		}
+		scaleX, scaleY = scale, scale
+		translateX = -vbX*scale + (w-vbW*scale)*factor("xMin", "xMid")
+		translateY = -vbY*scale + (h-vbH*scale)*factor("YMin", "YMid")
+	}
+	ret.base = svgMatrix{scaleX, 0, 0, scaleY, translateX, translateY}.then(svgMatrix{0.75, 0, 0, -0.75, 0, ret.height})
+	return ret
+}
+
+// convert 转换整个文档，遇到无法转换的内容时返回 errSVGUnsupported。
+func (c *svgConverter) convert() error {
+	fmt.Fprintf(&c.content, "%s cm\n", c.base)
+	style := &svgStyle{fill: "black", stroke: "none", fillOpacity: 1, strokeOpacity: 1, opacity: 1, strokeWidth: 1,
+		fillRule: "nonzero", lineCap: "butt", lineJoin: "miter", miterLimit: 4, dash: "none", fontSize: 16,
+		fontWeight: "normal", textAnchor: "start", color: "black"}
+	return c.convertChildren(c.root, c.inherit(style, c.root), c.base, 0)
+}
+
+func (c *svgConverter) convertChildren(n *svgNode, style *svgStyle, ctm svgMatrix, depth int) error {
+	for _, child := range n.children {
+		if err := c.convertNode(child, style, ctm, depth); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertNode 转换元素 n，ctm 为父元素用户坐标到页面坐标的变换。
+func (c *svgConverter) convertNode(n *svgNode, parentStyle *svgStyle, ctm svgMatrix, depth int) error {
+	switch n.name {
+	case "image", "foreignObject", "textPath":
+		return fmt.Errorf("%w <%s>", errSVGUnsupported, n.name)
+	case "svg", "g", "a", "switch", "use", "path", "rect", "circle", "ellipse", "line", "polyline", "polygon", "text":
+	default:
+		// 定义（渐变、剪切路径等在引用时处理）、元数据和未知元素不绘制
+		return nil
+	}
+	if "none" == n.attrs["display"] {
+		return nil
+	}
+	for _, attr := range []string{"filter", "mask", "marker-start", "marker-mid", "marker-end"} {
+		if value := n.attrs[attr]; "" != value && "none" != value {
+			return fmt.Errorf("%w [%s]", errSVGUnsupported, attr)
+		}
+	}
+	if 16 < depth {
+		return fmt.Errorf("%w: too many nested <use>", errSVGUnsupported)
+	}
+
+	style := c.inherit(parentStyle, n)
+	c.content.WriteString("q\n")
+	defer c.content.WriteString("Q\n")
+	if transform, ok := n.attrs["transform"]; ok {
+		m := parseSVGTransform(transform)
+		fmt.Fprintf(&c.content, "%s cm\n", m)
+		ctm = m.then(ctm)
+	}
+	if clipPath := n.attrs["clip-path"]; strings.HasPrefix(clipPath, "url(") {
+		if err := c.clip(clipPath); nil != err {
+			return err
+		}
+	}
+
+	switch n.name {
+	case "svg", "use":
+		x, y := svgLength(n.attrs["x"], c.viewport[0], style.fontSize, 0), svgLength(n.attrs["y"], c.viewport[1], style.fontSize, 0)
+		if 0 != x || 0 != y {
+			m := svgMatrix{1, 0, 0, 1, x, y}
+			fmt.Fprintf(&c.content, "%s cm\n", m)
+			ctm = m.then(ctm)
+		}
+		if "svg" == n.name {
+			return c.convertChildren(n, style, ctm, depth)
+		}
+		target := c.ids[strings.TrimPrefix(n.attrs["href"], "#")]
+		if nil == target {
+			return nil
+		}
+		if "symbol" == target.name {
+			return c.convertChildren(target, c.inherit(style, target), ctm, depth+1)
+		}
+		return c.convertNode(target, style, ctm, depth+1)
+	case "g", "a":
+		return c.convertChildren(n, style, ctm, depth)
+	case "switch":
+		// 使用第一个可以绘制的子元素，比如 draw.io 导出的 <foreignObject> 后备文本
+		for _, child := range n.children {
+			if "" != child.name && "foreignObject" != child.name {
+				return c.convertNode(child, style, ctm, depth)
+			}
+		}
+		return nil
+	}
+	if "hidden" == style.visibility || "collapse" == style.visibility {
+		return nil
+	}
+	if "text" == n.name {
+		c.text(n, style, ctm)
+		return nil
+	}
+	return c.drawPath(c.shape(n, style), style, ctm)
+}
+
+// inherit 返回继承样式 style 并应用元素 n 自身属性后的样式。
+func (c *svgConverter) inherit(style *svgStyle, n *svgNode) *svgStyle {
+	ret := *style
+	if fontSize, ok := n.attrs["font-size"]; ok {
+		ret.fontSize = svgLength(fontSize, style.fontSize, style.fontSize, style.fontSize)
+	}
+	for k, v := range n.attrs {
+		if "inherit" == v {
+			continue
+		}
+		switch k {
+		case "fill":
+			ret.fill = v
+		case "stroke":
+			ret.stroke = v
+		case "fill-opacity":
+			ret.fillOpacity = svgOpacity(v)
+		case "stroke-opacity":
+			ret.strokeOpacity = svgOpacity(v)
+		case "opacity":
+			// 不支持透明组，近似为子元素的不透明度相乘
+			ret.opacity *= svgOpacity(v)
+		case "stroke-width":
+			ret.strokeWidth = svgLength(v, math.Hypot(c.viewport[0], c.viewport[1])/math.Sqrt2, ret.fontSize, ret.strokeWidth)
+		case "fill-rule":
+			ret.fillRule = v
+		case "stroke-linecap":
+			ret.lineCap = v
+		case "stroke-linejoin":
+			ret.lineJoin = v
+		case "stroke-miterlimit":
+			ret.miterLimit = svgLength(v, 0, 0, ret.miterLimit)
+		case "stroke-dasharray":
+			ret.dash = v
+		case "font-weight":
+			ret.fontWeight = v
+		case "text-anchor":
+			ret.textAnchor = v
+		case "color":
+			ret.color = v
+		case "visibility":
+			ret.visibility = v
+		}
+	}
+	return &ret
+}
+
+func svgOpacity(value string) float64 {
+	return math.Max(0, math.Min(1, svgLength(value, 1, 0, 1)))
+}
+
+// shape 返回形状元素 n 的路径。
+func (c *svgConverter) shape(n *svgNode, style *svgStyle) []*svgSegment {
+	length := func(k string, base float64) float64 {
+		return svgLength(n.attrs[k], base, style.fontSize, 0)
+	}
+	vw, vh := c.viewport[0], c.viewport[1]
+	diagonal := math.Hypot(vw, vh) / math.Sqrt2
+
+	var d string
+	switch n.name {
+	case "path":
+		d = n.attrs["d"]
+	case "rect":
+		x, y, w, h := length("x", vw), length("y", vh), length("width", vw), length("height", vh)
+		if 0 >= w || 0 >= h {
+			return nil
+		}
+		rx, ry := svgLength(n.attrs["rx"], vw, style.fontSize, -1), svgLength(n.attrs["ry"], vh, style.fontSize, -1)
+		if 0 > rx {
+			rx = ry
+		}
+		if 0 > ry {
+			ry = rx
+		}
+		rx, ry = math.Max(0, math.Min(rx, w/2)), math.Max(0, math.Min(ry, h/2))
+		if 0 == rx || 0 == ry {
+			d = fmt.Sprintf("M%g %gH%gV%gH%gZ", x, y, x+w, y+h, x)
+		} else {
+			d = fmt.Sprintf("M%g %gH%gA%g %g 0 0 1 %g %gV%gA%g %g 0 0 1 %g %gH%gA%g %g 0 0 1 %g %gV%gA%g %g 0 0 1 %g %gZ",
+				x+rx, y, x+w-rx, rx, ry, x+w, y+ry, y+h-ry, rx, ry, x+w-rx, y+h, x+rx, rx, ry, x, y+h-ry, y+ry, rx, ry, x+rx, y)
+		}
+	case "circle", "ellipse":
+		cx, cy := length("cx", vw), length("cy", vh)
+		rx, ry := length("rx", vw), length("ry", vh)
+		if "circle" == n.name {
+			rx = length("r", diagonal)
+			ry = rx
+		}
+		if 0 >= rx || 0 >= ry {
+			return nil
+		}
+		d = fmt.Sprintf("M%g %gA%g %g 0 1 0 %g %gA%g %g 0 1 0 %g %gZ", cx-rx, cy, rx, ry, cx+rx, cy, rx, ry, cx-rx, cy)
+	case "line":
+		d = fmt.Sprintf("M%g %gL%g %g", length("x1", vw), length("y1", vh), length("x2", vw), length("y2", vh))
+	case "polyline", "polygon":
+		points := svgNumbers(n.attrs["points"])
+		if 4 > len(points) {
+			return nil
+		}
+		var parts []string
+		for _, p := range points[:len(points)/2*2] {
+			parts = append(parts, strconv.FormatFloat(p, 'g', -1, 64))
+		}
+		d = "M" + strings.Join(parts, " ")
+		if "polygon" == n.name {
+			d += "Z"
+		}
+	}
+	ret, _ := parseSVGPath(d)
+	return ret
+}
+
+// drawPath 按照样式 style 填充和描边路径 segments。
+func (c *svgConverter) drawPath(segments []*svgSegment, style *svgStyle, ctm svgMatrix) error {
+	if 0 == len(segments) {
+		return nil
+	}
+	fill, err := c.paint(style.fill, style, segments, ctm, false)
+	if nil != err {
+		return err
+	}
+	stroke := ""
+	if 0 < style.strokeWidth {
+		if stroke, err = c.paint(style.stroke, style, segments, ctm, true); nil != err {
+			return err
+		}
+	}
+	if "" == fill && "" == stroke {
+		return nil
+	}
+
+	if gstate := c.gstate(style.fillOpacity*style.opacity, style.strokeOpacity*style.opacity); "" != gstate {
+		fmt.Fprintf(&c.content, "/%s gs\n", gstate)
+	}
+	c.content.WriteString(fill)
+	c.content.WriteString(stroke)
+	if "" != stroke {
+		caps := map[string]int{"butt": 0, "round": 1, "square": 2}
+		joins := map[string]int{"miter": 0, "round": 1, "bevel": 2}
+		fmt.Fprintf(&c.content, "%s w %d J %d j %s M\n", svgNum(style.strokeWidth), caps[style.lineCap], joins[style.lineJoin], svgNum(math.Max(1, style.miterLimit)))
+		if dash := svgNumbers(style.dash); 0 < len(dash) {
+			if 1 == len(dash)%2 {
+				dash = append(dash, dash...)
+			}
+			var parts []string
+			sum := 0.0
+			for _, v := range dash {
+				parts = append(parts, svgNum(v))
+				sum += v
+			}
+			if 0 < sum {
+				fmt.Fprintf(&c.content, "[%s] 0 d\n", strings.Join(parts, " "))
+			}
+		}
+	}
+	writeSVGPath(&c.content, segments, svgIdentity)
+
+	evenOdd := ""
+	if "evenodd" == style.fillRule {
+		evenOdd = "*"
+	}
+	switch {
+	case "" != fill && "" != stroke:
+		c.content.WriteString("B" + evenOdd + "\n")
+	case "" != fill:
+		c.content.WriteString("f" + evenOdd + "\n")
+	default:
+		c.content.WriteString("S\n")
+	}
+	return nil
+}
+
+// writeSVGPath 将经过变换 m 的路径 segments 写入内容流 w。
+func writeSVGPath(w io.Writer, segments []*svgSegment, m svgMatrix) {
+	for _, seg := range segments {
+		var coords []string
+		for i := 0; i+1 < len(seg.points); i += 2 {
+			x, y := m.apply(seg.points[i], seg.points[i+1])
+			coords = append(coords, svgNum(x), svgNum(y))
+		}
+		switch seg.op {
+		case 'M':
+			fmt.Fprintf(w, "%s m\n", strings.Join(coords, " "))
+		case 'L':
+			fmt.Fprintf(w, "%s l\n", strings.Join(coords, " "))
+		case 'C':
+			fmt.Fprintf(w, "%s c\n", strings.Join(coords, " "))
+		case 'Z':
+			io.WriteString(w, "h\n")
+		}
+	}
+}
+
+// paint 返回设置填充（stroke 为 false）或者描边颜色的操作，value 为 none 时返回空。
+func (c *svgConverter) paint(value string, style *svgStyle, segments []*svgSegment, ctm svgMatrix, stroke bool) (string, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "url(") {
+		end := strings.Index(value, ")")
+		if 0 > end {
+			return "", nil
+		}
+		id := strings.TrimPrefix(strings.Trim(value[4:end], `'" `), "#")
+		if target := c.ids[id]; nil != target {
+			switch target.name {
+			case "linearGradient", "radialGradient":
+				return c.gradient(target, segments, ctm, stroke), nil
+			case "pattern":
+				return "", fmt.Errorf("%w <pattern>", errSVGUnsupported)
+			}
+		}
+		// 引用无效时使用后备颜色
+		value = strings.TrimSpace(value[end+1:])
+	}
+	switch strings.ToLower(value) {
+	case "", "none", "transparent":
+		return "", nil
+	case "currentcolor":
+		value = style.color
+	}
+
+	color := parseSVGColor(value)
+	if nil == color {
+		color = &RGB{}
+	}
+	return svgColorOp(color, stroke), nil
+}
+
+func svgColorOp(color *RGB, stroke bool) string {
+	op := "rg"
+	if stroke {
+		op = "RG"
+	}
+	return fmt.Sprintf("%s %s\n", svgRGB(color), op)
+}
+
+func svgRGB(color *RGB) string {
+	return svgNum(float64(color.R)/255) + " " + svgNum(float64(color.G)/255) + " " + svgNum(float64(color.B)/255)
+}
+
+// svgStop 描述了渐变中的一个颜色节点。
+type svgStop struct {
+	offset float64
+	color  *RGB
+}
+
+// gradient 为路径 segments 生成渐变 n 的填充图案，返回使用该图案的操作。
+func (c *svgConverter) gradient(n *svgNode, segments []*svgSegment, ctm svgMatrix, stroke bool) string {
+	attrs, stops := c.gradientDef(n)
+	switch len(stops) {
+	case 0:
+		return ""
+	case 1:
+		return svgColorOp(stops[0].color, stroke)
+	}
+
+	m := parseSVGTransform(attrs["gradientTransform"])
+	base := c.viewport
+	if "userSpaceOnUse" != attrs["gradientUnits"] {
+		minX, minY, maxX, maxY := svgBounds(segments)
+		if maxX == minX || maxY == minY {
+			return ""
+		}
+		m = m.then(svgMatrix{maxX - minX, 0, 0, maxY - minY, minX, minY})
+		base = [2]float64{1, 1}
+	}
+	m = m.then(ctm)
+	coord := func(k, defaultValue string, base float64) string {
+		value := attrs[k]
+		if "" == value {
+			value = defaultValue
+		}
+		return svgNum(svgLength(value, base, 16, 0))
+	}
+
+	var shading string
+	function := svgGradientFunction(stops)
+	if "linearGradient" == n.name {
+		shading = fmt.Sprintf("<< /ShadingType 2 /ColorSpace /DeviceRGB /Coords [%s %s %s %s] /Function %s /Extend [true true] >>",
+			coord("x1", "0%", base[0]), coord("y1", "0%", base[1]), coord("x2", "100%", base[0]), coord("y2", "0%", base[1]), function)
+	} else {
+		cx, cy := coord("cx", "50%", base[0]), coord("cy", "50%", base[1])
+		fx, fy := coord("fx", attrs["cx"], base[0]), coord("fy", attrs["cy"], base[1])
+		if "" == attrs["fx"] && "" == attrs["cx"] {
+			fx = cx
+		}
+		if "" == attrs["fy"] && "" == attrs["cy"] {
+			fy = cy
+		}
+		radius := coord("r", "50%", math.Hypot(base[0], base[1])/math.Sqrt2)
+		shading = fmt.Sprintf("<< /ShadingType 3 /ColorSpace /DeviceRGB /Coords [%s %s 0 %s %s %s] /Function %s /Extend [true true] >>",
+			fx, fy, cx, cy, radius, function)
+	}
+
+	name := "P" + strconv.Itoa(len(c.patterns))
+	c.patterns = append(c.patterns, fmt.Sprintf("/%s << /PatternType 2 /Shading %s /Matrix [%s] >>", name, shading, m))
+	if stroke {
+		return "/Pattern CS /" + name + " SCN\n"
+	}
+	return "/Pattern cs /" + name + " scn\n"
+}
+
+// gradientDef 返回渐变 n 的属性和颜色节点，没有定义的属性和颜色节点从 href 引用的渐变继承。
+func (c *svgConverter) gradientDef(n *svgNode) (attrs map[string]string, stops []*svgStop) {
+	attrs = map[string]string{}
+	for depth := 0; nil != n && 8 > depth; depth++ {
+		for k, v := range n.attrs {
+			if _, ok := attrs[k]; !ok {
+				attrs[k] = v
+			}
+		}
+		if 0 == len(stops) {
+			for _, child := range n.children {
+				if "stop" != child.name {
+					continue
+				}
+				stop := &svgStop{offset: math.Max(0, math.Min(1, svgLength(child.attrs["offset"], 1, 0, 0))), color: parseSVGColor(child.attrs["stop-color"])}
+				if nil == stop.color {
+					stop.color = &RGB{}
+				}
+				if 0 < len(stops) {
+					stop.offset = math.Max(stop.offset, stops[len(stops)-1].offset)
+				}
+				stops = append(stops, stop)
+			}
+		}
+		n = c.ids[strings.TrimPrefix(n.attrs["href"], "#")]
+	}
+	return
+}
+
+// svgGradientFunction 返回渐变颜色节点 stops 对应的 PDF 函数，多个节点时使用分段函数。
+func svgGradientFunction(stops []*svgStop) string {
+	if first := stops[0]; 0 < first.offset {
+		stops = append([]*svgStop{{0, first.color}}, stops...)
+	}
+	if last := stops[len(stops)-1]; 1 > last.offset {
+		stops = append(stops, &svgStop{1, last.color})
+	}
+
+	interpolation := func(from, to *svgStop) string {
+		return fmt.Sprintf("<< /FunctionType 2 /Domain [0 1] /C0 [%s] /C1 [%s] /N 1 >>", svgRGB(from.color), svgRGB(to.color))
+	}
+	if 2 == len(stops) {
+		return interpolation(stops[0], stops[1])
+	}
+	var functions, bounds, encode []string
+	for i := 0; i+1 < len(stops); i++ {
+		functions = append(functions, interpolation(stops[i], stops[i+1]))
+		encode = append(encode, "0 1")
+		if 0 < i {
+			bounds = append(bounds, svgNum(stops[i].offset))
+		}
+	}
+	return fmt.Sprintf("<< /FunctionType 3 /Domain [0 1] /Functions [%s] /Bounds [%s] /Encode [%s] >>",
+		strings.Join(functions, " "), strings.Join(bounds, " "), strings.Join(encode, " "))
+}
+
+// svgBounds 返回路径 segments 的包围盒（包括控制点）。
+func svgBounds(segments []*svgSegment) (minX, minY, maxX, maxY float64) {
+	minX, minY, maxX, maxY = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, seg := range segments {
+		for i := 0; i+1 < len(seg.points); i += 2 {
+			minX, maxX = math.Min(minX, seg.points[i]), math.Max(maxX, seg.points[i])
+			minY, maxY = math.Min(minY, seg.points[i+1]), math.Max(maxY, seg.points[i+1])
+		}
+	}
+	return
+}
+
+// gstate 返回填充不透明度为 fillAlpha、描边不透明度为 strokeAlpha 的图形状态名，都不透明时返回空。
+func (c *svgConverter) gstate(fillAlpha, strokeAlpha float64) string {
+	if 1 <= fillAlpha && 1 <= strokeAlpha {
+		return ""
+	}
+	key := fmt.Sprintf("/ca %s /CA %s", svgNum(fillAlpha), svgNum(strokeAlpha))
+	if name, ok := c.gstateNames[key]; ok {
+		return name
+	}
+	name := "GS" + strconv.Itoa(len(c.gstates))
+	c.gstates = append(c.gstates, fmt.Sprintf("/%s << %s >>", name, key))
+	c.gstateNames[key] = name
+	return name
+}
+
+// clip 设置 clip-path 属性 value 引用的剪切路径。
+func (c *svgConverter) clip(value string) error {
+	end := strings.Index(value, ")")
+	if 0 > end {
+		return nil
+	}
+	target := c.ids[strings.TrimPrefix(strings.Trim(value[4:end], `'" `), "#")]
+	if nil == target || "clipPath" != target.name {
+		return nil
+	}
+	if "objectBoundingBox" == target.attrs["clipPathUnits"] {
+		return fmt.Errorf("%w [clipPathUnits]", errSVGUnsupported)
+	}
+
+	m := parseSVGTransform(target.attrs["transform"])
+	style := c.inherit(&svgStyle{fontSize: 16}, target)
+	empty, evenOdd := true, false
+	for _, child := range target.children {
+		if "" == child.name {
+			continue
+		}
+		segments := c.shape(child, style)
+		if 0 == len(segments) {
+			continue
+		}
+		writeSVGPath(&c.content, segments, parseSVGTransform(child.attrs["transform"]).then(m))
+		empty = false
+		evenOdd = evenOdd || "evenodd" == child.attrs["clip-rule"]
+	}
+	switch {
+	case empty:
+		// 空的剪切路径会隐藏元素
+		c.content.WriteString("0 0 0 0 re W n\n")
+	case evenOdd:
+		c.content.WriteString("W* n\n")
+	default:
+		c.content.WriteString("W n\n")
+	}
+	return nil
+}
+
+// text 记录文本元素 n 中的文本，<tspan> 指定位置时另起一段。
+func (c *svgConverter) text(n *svgNode, style *svgStyle, ctm svgMatrix) {
+	var x, y float64
+	var last *svgText
+	var walk func(n *svgNode, style *svgStyle)
+	walk = func(n *svgNode, style *svgStyle) {
+		moved := false
+		if v := svgNumbers(n.attrs["x"]); 0 < len(v) {
+			x, moved = v[0], true
+		}
+		if v := svgNumbers(n.attrs["y"]); 0 < len(v) {
+			y, moved = v[0], true
+		}
+		if v := strings.Fields(strings.ReplaceAll(n.attrs["dx"], ",", " ")); 0 < len(v) {
+			x, moved = x+svgLength(v[0], c.viewport[0], style.fontSize, 0), true
+		}
+		if v := strings.Fields(strings.ReplaceAll(n.attrs["dy"], ",", " ")); 0 < len(v) {
+			y, moved = y+svgLength(v[0], c.viewport[1], style.fontSize, 0), true
+		}
+		if moved {
+			last = nil
+		}
+
+		for _, child := range n.children {
+			if "tspan" == child.name || "a" == child.name {
+				if "none" != child.attrs["display"] {
+					walk(child, c.inherit(style, child))
+				}
+				continue
+			}
+			if "" != child.name {
+				continue
+			}
+			text := strings.Join(strings.Fields(child.text), " ")
+			if "" == text {
+				continue
+			}
+			if nil != last {
+				last.text += " " + text
+				continue
+			}
+			color := parseSVGColor(style.fill)
+			if "none" == style.fill {
+				continue
+			} else if nil == color {
+				color = &RGB{}
+			}
+			px, py := ctm.apply(x, y)
+			weight, _ := strconv.Atoi(style.fontWeight)
+			last = &svgText{x: px, y: c.height - py, size: style.fontSize * ctm.scale(), text: text, anchor: style.textAnchor,
+				bold: "bold" == style.fontWeight || "bolder" == style.fontWeight || 600 <= weight, color: color}
+			c.texts = append(c.texts, last)
+		}
+	}
+	walk(n, style)
+}
+
+// pdf 返回包含转换结果的单页 PDF。
+func (c *svgConverter) pdf() []byte {
+	resources := "<<"
+	if 0 < len(c.gstates) {
+		resources += " /ExtGState << " + strings.Join(c.gstates, " ") + " >>"
+	}
+	if 0 < len(c.patterns) {
+		resources += " /Pattern << " + strings.Join(c.patterns, " ") + " >>"
+	}
+	resources += " >>"
+
+	content := bytes.Buffer{}
+	w := zlib.NewWriter(&content)
+	w.Write(c.content.Bytes())
+	w.Close()
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources %s /Contents 4 0 R >>", svgNum(c.width), svgNum(c.height), resources),
+		fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", content.Len(), content.Bytes()),
+	}
+	buf := bytes.Buffer{}
+	buf.WriteString("%PDF-1.4\n")
+	var offsets []int
+	for i, obj := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,542 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// svgNode 描述了 SVG 文档中的元素，name 为空时是文本内容。
+type svgNode struct {
+	name     string
+	attrs    map[string]string
+	children []*svgNode
+	text     string
+}
+
+// isSVG 判断图片数据 data 是否为 SVG 文档。
+func isSVG(data []byte) bool {
+	head := data
+	if 1024 < len(head) {
+		head = head[:1024]
+	}
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	head = bytes.TrimSpace(head)
+	return bytes.HasPrefix(head, []byte("<")) && bytes.Contains(head, []byte("<svg"))
+}
+
+// parseSVGTree 将 SVG 文档 data 解析为元素树，元素名和属性名去掉命名空间前缀（xlink:href 为 href），style 属性和样式表中的声明合并到属性中。
+func parseSVGTree(data []byte) (root *svgNode, err error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	var stack []*svgNode
+	for {
+		token, err := decoder.Token()
+		if io.EOF == err {
+			break
+		}
+		if nil != err {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &svgNode{name: t.Name.Local, attrs: map[string]string{}}
+			for _, attr := range t.Attr {
+				n.attrs[attr.Name.Local] = strings.TrimSpace(attr.Value)
+			}
+			if 0 < len(stack) {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if nil == root {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if 0 < len(stack) {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if 0 < len(stack) {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, &svgNode{text: string(t)})
+			}
+		}
+	}
+	if nil == root || "svg" != root.name {
+		return nil, errors.New("root element is not svg")
+	}
+
+	applySVGStyles(root, parseSVGStyleSheet(root))
+	return root, nil
+}
+
+// svgStyleRule 描述了样式表中的一条规则，只支持元素名、.class 和 #id 选择器。
+type svgStyleRule struct {
+	selector     string
+	declarations map[string]string
+}
+
+var svgStyleComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// parseSVGStyleSheet 解析文档中所有 <style> 元素的样式表。
+func parseSVGStyleSheet(root *svgNode) (ret []*svgStyleRule) {
+	var css strings.Builder
+	root.walk(func(n *svgNode) {
+		if "style" == n.name {
+			css.WriteString(n.textContent())
+		}
+	})
+
+	text := svgStyleComment.ReplaceAllString(css.String(), "")
+	for _, block := range strings.Split(text, "}") {
+		parts := strings.SplitN(block, "{", 2)
+		if 2 != len(parts) {
+			continue
+		}
+		declarations := parseSVGDeclarations(parts[1])
+		for _, selector := range strings.Split(parts[0], ",") {
+			selector = strings.TrimSpace(selector)
+			if "" != selector && !strings.ContainsAny(selector, " >+~:[") {
+				ret = append(ret, &svgStyleRule{selector: selector, declarations: declarations})
+			}
+		}
+	}
+	return
+}
+
+// parseSVGDeclarations 解析形如 fill:#fff;stroke:none 的样式声明。
+func parseSVGDeclarations(text string) map[string]string {
+	ret := map[string]string{}
+	for _, declaration := range strings.Split(text, ";") {
+		kv := strings.SplitN(declaration, ":", 2)
+		if 2 != len(kv) {
+			continue
+		}
+		value := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(kv[1]), "!important"))
+		ret[strings.ToLower(strings.TrimSpace(kv[0]))] = value
+	}
+	return ret
+}
+
+// applySVGStyles 按照属性、样式表、style 属性的优先级将样式合并到元素属性中。
+func applySVGStyles(root *svgNode, rules []*svgStyleRule) {
+	root.walk(func(n *svgNode) {
+		if "" == n.name {
+			return
+		}
+		for _, rule := range rules {
+			if n.matches(rule.selector) {
+				for k, v := range rule.declarations {
+					n.attrs[k] = v
+				}
+			}
+		}
+		if style, ok := n.attrs["style"]; ok {
+			for k, v := range parseSVGDeclarations(style) {
+				n.attrs[k] = v
+			}
+		}
+	})
+}
+
+func (n *svgNode) matches(selector string) bool {
+	switch {
+	case strings.HasPrefix(selector, "."):
+		for _, class := range strings.Fields(n.attrs["class"]) {
+			if class == selector[1:] {
+				return true
+			}
+		}
+		return false
+	case strings.HasPrefix(selector, "#"):
+		return n.attrs["id"] == selector[1:]
+	}
+	return n.name == selector || "*" == selector
+}
+
+func (n *svgNode) walk(visitor func(n *svgNode)) {
+	visitor(n)
+	for _, c := range n.children {
+		c.walk(visitor)
+	}
+}
+
+// textContent 返回元素中所有文本内容。
+func (n *svgNode) textContent() string {
+	if "" == n.name {
+		return n.text
+	}
+	var buf strings.Builder
+	for _, c := range n.children {
+		buf.WriteString(c.textContent())
+	}
+	return buf.String()
+}
+
+// svgMatrix 描述了仿射变换 [a b c d e f]，将 (x, y) 变换为 (a·x + c·y + e, b·x + d·y + f)。
+type svgMatrix [6]float64
+
+var svgIdentity = svgMatrix{1, 0, 0, 1, 0, 0}
+
+// then 返回先进行变换 m 再进行变换 n 的变换。
+func (m svgMatrix) then(n svgMatrix) svgMatrix {
+	return svgMatrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m svgMatrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// scale 返回变换的平均缩放倍数。
+func (m svgMatrix) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+func (m svgMatrix) String() string {
+	var parts []string
+	for _, v := range m {
+		parts = append(parts, svgNum(v))
+	}
+	return strings.Join(parts, " ")
+}
+
+var svgTransformFunc = regexp.MustCompile(`(matrix|translate|scale|rotate|skewX|skewY)\s*\(([^)]*)\)`)
+
+// parseSVGTransform 解析 transform 属性，比如 translate(10 20) rotate(45)。
+func parseSVGTransform(text string) svgMatrix {
+	ret := svgIdentity
+	for _, groups := range svgTransformFunc.FindAllStringSubmatch(text, -1) {
+		args := svgNumbers(groups[2])
+		arg := func(i int, defaultValue float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return defaultValue
+		}
+
+		m := svgIdentity
+		switch groups[1] {
+		case "matrix":
+			if 6 == len(args) {
+				copy(m[:], args)
+			}
+		case "translate":
+			m[4], m[5] = arg(0, 0), arg(1, 0)
+		case "scale":
+			m[0] = arg(0, 1)
+			m[3] = arg(1, m[0])
+		case "rotate":
+			a := arg(0, 0) * math.Pi / 180
+			cx, cy := arg(1, 0), arg(2, 0)
+			m = svgMatrix{1, 0, 0, 1, -cx, -cy}.then(svgMatrix{math.Cos(a), math.Sin(a), -math.Sin(a), math.Cos(a), 0, 0}).then(svgMatrix{1, 0, 0, 1, cx, cy})
+		case "skewX":
+			m[2] = math.Tan(arg(0, 0) * math.Pi / 180)
+		case "skewY":
+			m[1] = math.Tan(arg(0, 0) * math.Pi / 180)
+		}
+		// 列表中靠后的变换先作用于坐标
+		ret = m.then(ret)
+	}
+	return ret
+}
+
+var svgNumber = regexp.MustCompile(`[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+
+// svgNumbers 解析以空白或者逗号分隔的数字列表。
+func svgNumbers(text string) (ret []float64) {
+	for _, s := range svgNumber.FindAllString(text, -1) {
+		v, _ := strconv.ParseFloat(s, 64)
+		ret = append(ret, v)
+	}
+	return
+}
+
+// svgLength 解析长度 value（用户单位），百分比相对于 base，em 相对于 fontSize，为空或者无法解析时返回 defaultValue。
+func svgLength(value string, base, fontSize, defaultValue float64) float64 {
+	value = strings.TrimSpace(value)
+	num := svgNumber.FindString(value)
+	if "" == num || !strings.HasPrefix(value, num) {
+		return defaultValue
+	}
+	v, _ := strconv.ParseFloat(num, 64)
+	switch strings.TrimSpace(value[len(num):]) {
+	case "%":
+		return v * base / 100
+	case "em":
+		return v * fontSize
+	case "pt":
+		return v * 4 / 3
+	case "pc":
+		return v * 16
+	case "mm":
+		return v * 96 / 25.4
+	case "cm":
+		return v * 96 / 2.54
+	case "in":
+		return v * 96
+	}
+	return v
+}
+
+// svgNum 格式化 PDF 内容流中的数字。
+func svgNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10000)/10000, 'f', -1, 64)
+}
+
+// svgColorNames 为常用的 SVG 颜色名。
+var svgColorNames = map[string]*RGB{
+	"black": {0, 0, 0}, "white": {255, 255, 255}, "red": {255, 0, 0}, "green": {0, 128, 0},
+	"blue": {0, 0, 255}, "yellow": {255, 255, 0}, "orange": {255, 165, 0}, "purple": {128, 0, 128},
+	"gray": {128, 128, 128}, "grey": {128, 128, 128}, "silver": {192, 192, 192}, "maroon": {128, 0, 0},
+	"navy": {0, 0, 128}, "teal": {0, 128, 128}, "olive": {128, 128, 0}, "lime": {0, 255, 0},
+	"aqua": {0, 255, 255}, "cyan": {0, 255, 255}, "fuchsia": {255, 0, 255}, "magenta": {255, 0, 255},
+	"lightgray": {211, 211, 211}, "lightgrey": {211, 211, 211}, "darkgray": {169, 169, 169}, "darkgrey": {169, 169, 169},
+	"pink": {255, 192, 203}, "brown": {165, 42, 42}, "gold": {255, 215, 0}, "steelblue": {70, 130, 180},
+	"skyblue": {135, 206, 235}, "lightblue": {173, 216, 230}, "darkblue": {0, 0, 139}, "darkgreen": {0, 100, 0},
+	"darkred": {139, 0, 0}, "whitesmoke": {245, 245, 245}, "gainsboro": {220, 220, 220}, "tomato": {255, 99, 71},
+}
+
+// parseSVGColor 解析颜色 text，支持 #rgb、#rrggbb、rgb() 和颜色名，无法解析时返回 nil。
+func parseSVGColor(text string) *RGB {
+	text = strings.ToLower(strings.TrimSpace(text))
+	switch {
+	case strings.HasPrefix(text, "#"):
+		hex := text[1:]
+		if 3 == len(hex) || 4 == len(hex) {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if 6 > len(hex) {
+			return nil
+		}
+		v, err := strconv.ParseUint(hex[:6], 16, 32)
+		if nil != err {
+			return nil
+		}
+		return &RGB{uint8(v >> 16), uint8(v >> 8), uint8(v)}
+	case strings.HasPrefix(text, "rgb"):
+		start, end := strings.Index(text, "("), strings.Index(text, ")")
+		if 0 > start || start > end {
+			return nil
+		}
+		var channels []uint8
+		for _, part := range strings.FieldsFunc(text[start+1:end], func(c rune) bool { return ',' == c || ' ' == c || '/' == c }) {
+			v := svgLength(part, 255, 0, 0)
+			channels = append(channels, uint8(math.Max(0, math.Min(255, math.Round(v)))))
+		}
+		if 3 > len(channels) {
+			return nil
+		}
+		return &RGB{channels[0], channels[1], channels[2]}
+	}
+	return svgColorNames[text]
+}
+
+// svgSegment 描述了路径中的一段，op 为 M、L、C、Z，points 为终点（C 为两个控制点和终点）。
+type svgSegment struct {
+	op     byte
+	points []float64
+}
+
+// parseSVGPath 解析路径数据 d，二次贝塞尔曲线和椭圆弧转换为三次贝塞尔曲线，所有坐标转换为绝对坐标。
+//
+// 路径数据有错误时返回错误之前的部分，与浏览器的处理方式一致。
+func parseSVGPath(d string) (ret []*svgSegment, err error) {
+	s := &svgPathScanner{d: d}
+	var x, y, startX, startY, ctrlX, ctrlY float64
+	var cmd, prev byte
+	for {
+		s.skipSeparators()
+		if s.pos >= len(s.d) {
+			return ret, nil
+		}
+		if c := s.d[s.pos]; strings.IndexByte("MmLlHhVvCcSsQqTtAaZz", c) >= 0 {
+			cmd = c
+			s.pos++
+		} else if 0 == cmd || 'Z' == cmd || 'z' == cmd {
+			return ret, fmt.Errorf("invalid path data at %d", s.pos)
+		} else if 'M' == cmd || 'm' == cmd {
+			// moveto 后的坐标对视为 lineto
+			cmd = cmd - 'M' + 'L'
+		}
+
+		rel := 'a' <= cmd
+		ox, oy := 0.0, 0.0
+		if rel {
+			ox, oy = x, y
+		}
+		var args []float64
+		if 'Z' != cmd && 'z' != cmd {
+			if args, err = s.args(cmd); nil != err {
+				return ret, err
+			}
+		}
+
+		op := cmd &^ 0x20 // 转换为大写
+		switch op {
+		case 'M':
+			x, y = ox+args[0], oy+args[1]
+			startX, startY = x, y
+			ret = append(ret, &svgSegment{op: 'M', points: []float64{x, y}})
+		case 'L':
+			x, y = ox+args[0], oy+args[1]
+			ret = append(ret, &svgSegment{op: 'L', points: []float64{x, y}})
+		case 'H':
+			x = ox + args[0]
+			ret = append(ret, &svgSegment{op: 'L', points: []float64{x, y}})
+		case 'V':
+			y = oy + args[0]
+			ret = append(ret, &svgSegment{op: 'L', points: []float64{x, y}})
+		case 'C', 'S':
+			var x1, y1 float64
+			if 'C' == op {
+				x1, y1 = ox+args[0], oy+args[1]
+				args = args[2:]
+			} else {
+				x1, y1 = x, y
+				if 'C' == prev || 'S' == prev {
+					x1, y1 = 2*x-ctrlX, 2*y-ctrlY
+				}
+			}
+			ctrlX, ctrlY = ox+args[0], oy+args[1]
+			x, y = ox+args[2], oy+args[3]
+			ret = append(ret, &svgSegment{op: 'C', points: []float64{x1, y1, ctrlX, ctrlY, x, y}})
+		case 'Q', 'T':
+			qx, qy := x, y
+			if 'Q' == op {
+				qx, qy = ox+args[0], oy+args[1]
+				args = args[2:]
+			} else if 'Q' == prev || 'T' == prev {
+				qx, qy = 2*x-ctrlX, 2*y-ctrlY
+			}
+			ex, ey := ox+args[0], oy+args[1]
+			ret = append(ret, &svgSegment{op: 'C', points: []float64{x + 2*(qx-x)/3, y + 2*(qy-y)/3, ex + 2*(qx-ex)/3, ey + 2*(qy-ey)/3, ex, ey}})
+			ctrlX, ctrlY = qx, qy
+			x, y = ex, ey
+		case 'A':
+			ex, ey := ox+args[5], oy+args[6]
+			ret = append(ret, svgArc(x, y, args[0], args[1], args[2], 0 != args[3], 0 != args[4], ex, ey)...)
+			x, y = ex, ey
+		case 'Z':
+			x, y = startX, startY
+			ret = append(ret, &svgSegment{op: 'Z'})
+		}
+		prev = op
+	}
+}
+
+type svgPathScanner struct {
+	d   string
+	pos int
+}
+
+func (s *svgPathScanner) skipSeparators() {
+	for s.pos < len(s.d) && strings.IndexByte(" \t\r\n,", s.d[s.pos]) >= 0 {
+		s.pos++
+	}
+}
+
+// args 读取命令 cmd 的参数，椭圆弧的两个标志位可以不用分隔符。
+func (s *svgPathScanner) args(cmd byte) (ret []float64, err error) {
+	count := map[byte]int{'M': 2, 'L': 2, 'H': 1, 'V': 1, 'C': 6, 'S': 4, 'Q': 4, 'T': 2, 'A': 7}[cmd&^0x20]
+	for i := 0; i < count; i++ {
+		s.skipSeparators()
+		if 'A' == cmd&^0x20 && (3 == i || 4 == i) && s.pos < len(s.d) && ('0' == s.d[s.pos] || '1' == s.d[s.pos]) {
+			ret = append(ret, float64(s.d[s.pos]-'0'))
+			s.pos++
+			continue
+		}
+		num := svgNumber.FindString(s.d[s.pos:])
+		if "" == num || !strings.HasPrefix(s.d[s.pos:], num) {
+			return nil, fmt.Errorf("invalid path data at %d", s.pos)
+		}
+		v, _ := strconv.ParseFloat(num, 64)
+		ret = append(ret, v)
+		s.pos += len(num)
+	}
+	return
+}
+
+// svgArc 将从 (x1, y1) 到 (x2, y2) 的椭圆弧转换为三次贝塞尔曲线，参见 SVG 规范附录 F.6。
+func svgArc(x1, y1, rx, ry, angle float64, largeArc, sweep bool, x2, y2 float64) (ret []*svgSegment) {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if 0 == rx || 0 == ry || (x1 == x2 && y1 == y2) {
+		return []*svgSegment{{op: 'L', points: []float64{x2, y2}}}
+	}
+
+	phi := angle * math.Pi / 180
+	cos, sin := math.Cos(phi), math.Sin(phi)
+	dx, dy := (x1-x2)/2, (y1-y2)/2
+	px, py := cos*dx+sin*dy, -sin*dx+cos*dy
+	if lambda := px*px/(rx*rx) + py*py/(ry*ry); 1 < lambda {
+		rx, ry = rx*math.Sqrt(lambda), ry*math.Sqrt(lambda)
+	}
+
+	num := rx*rx*ry*ry - rx*rx*py*py - ry*ry*px*px
+	den := rx*rx*py*py + ry*ry*px*px
+	coef := math.Sqrt(math.Max(0, num/den))
+	if largeArc == sweep {
+		coef = -coef
+	}
+	cxp, cyp := coef*rx*py/ry, -coef*ry*px/rx
+	cx, cy := cos*cxp-sin*cyp+(x1+x2)/2, sin*cxp+cos*cyp+(y1+y2)/2
+
+	angleOf := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := angleOf(1, 0, (px-cxp)/rx, (py-cyp)/ry)
+	delta := angleOf((px-cxp)/rx, (py-cyp)/ry, (-px-cxp)/rx, (-py-cyp)/ry)
+	if !sweep && 0 < delta {
+		delta -= 2 * math.Pi
+	} else if sweep && 0 > delta {
+		delta += 2 * math.Pi
+	}
+
+	// 每段不超过 90 度
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(n)
+	k := 4.0 / 3 * math.Tan(step/4)
+	point := func(t float64) (float64, float64) {
+		x, y := rx*math.Cos(t), ry*math.Sin(t)
+		return cos*x - sin*y + cx, sin*x + cos*y + cy
+	}
+	derivative := func(t float64) (float64, float64) {
+		x, y := -rx*math.Sin(t), ry*math.Cos(t)
+		return cos*x - sin*y, sin*x + cos*y
+	}
+	for i := 0; i < n; i++ {
+		t1, t2 := theta+float64(i)*step, theta+float64(i+1)*step
+		sx, sy := point(t1)
+		ex, ey := point(t2)
+		d1x, d1y := derivative(t1)
+		d2x, d2y := derivative(t2)
+		if i == n-1 {
+			ex, ey = x2, y2
+		}
+		ret = append(ret, &svgSegment{op: 'C', points: []float64{sx + k*d1x, sy + k*d1y, ex - k*d2x, ey - k*d2y, ex, ey}})
+	}
+	return
+}
@@ -0,0 +1,121 @@
+// Lute PDF - 一款通过 Markdown 生成 PDF 的小工具
+// Copyright (c) 2020-present, b3log.org
+//
+// LianDi is licensed under Mulan PSL v2.
+// You can use this software according to the terms and conditions of the Mulan PSL v2.
+// You may obtain a copy of Mulan PSL v2 at:
+//         http://license.coscl.org.cn/MulanPSL2
+// THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR PURPOSE.
+// See the Mulan PSL v2 for more details.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var (
+	pdfTrailerSize = regexp.MustCompile(`/Size (\d+)`)
+	pdfTrailerRoot = regexp.MustCompile(`/Root (\d+) 0 R`)
+	pdfStartXref   = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	pdfPagesKids   = regexp.MustCompile(`/Type /Pages[^>]*?/Kids \[([^\]]*)\]`)
+	pdfObjectRef   = regexp.MustCompile(`(\d+) 0 R`)
+)
+
+// pdfUpdate 描述了对 gopdf 生成的文档的一次 PDF 增量更新。
+//
+// gopdf 不支持的功能通过在原文档之后追加新对象以及改写后的对象实现，最后写入新的交叉引用表，原文档内容保持不变。
+type pdfUpdate struct {
+	data    []byte        // 原文档
+	buf     *bytes.Buffer // 更新后的文档
+	root    int           // 目录对象编号
+	pages   []int         // 页面对象编号，按照页码排列
+	prev    string        // 原交叉引用表的位置
+	nextID  int           // 下一个新对象的编号
+	offsets map[int]int   // 写入的对象在更新后文档中的位置
+}
+
+// newPdfUpdate 解析文档 data 的 trailer 和页面树，准备在其后追加增量更新，文档结构无法识别时返回错误。
+func newPdfUpdate(data []byte) (*pdfUpdate, error) {
+	trailerStart := bytes.LastIndex(data, []byte("trailer"))
+	if 0 > trailerStart {
+		return nil, errors.New("pdf trailer not found")
+	}
+	trailer := data[trailerStart:]
+	sizeGroups := pdfTrailerSize.FindSubmatch(trailer)
+	rootGroups := pdfTrailerRoot.FindSubmatch(trailer)
+	xrefGroups := pdfStartXref.FindSubmatch(trailer)
+	kidsGroups := pdfPagesKids.FindSubmatch(data)
+	if nil == sizeGroups || nil == rootGroups || nil == xrefGroups || nil == kidsGroups {
+		return nil, errors.New("unsupported pdf structure")
+	}
+
+	ret := &pdfUpdate{data: data, buf: bytes.NewBuffer(append([]byte{}, data...)), prev: string(xrefGroups[1]), offsets: map[int]int{}}
+	ret.nextID, _ = strconv.Atoi(string(sizeGroups[1]))
+	ret.root, _ = strconv.Atoi(string(rootGroups[1]))
+	for _, ref := range pdfObjectRef.FindAllSubmatch(kidsGroups[1], -1) {
+		page, _ := strconv.Atoi(string(ref[1]))
+		ret.pages = append(ret.pages, page)
+	}
+	if '\n' != data[len(data)-1] {
+		ret.buf.WriteByte('\n')
+	}
+	return ret, nil
+}
+
+// newID 分配一个新对象编号。
+func (u *pdfUpdate) newID() (ret int) {
+	ret = u.nextID
+	u.nextID++
+	return
+}
+
+// page 返回页码 page（从 1 开始）对应的页面对象编号。
+func (u *pdfUpdate) page(page int) (int, error) {
+	if 1 > page || page > len(u.pages) {
+		return 0, fmt.Errorf("pdf page [%d] out of range", page)
+	}
+	return u.pages[page-1], nil
+}
+
+// writeObject 写入编号为 id 的对象，id 为已有对象的编号时替换该对象。
+func (u *pdfUpdate) writeObject(id int, content string) {
+	u.offsets[id] = u.buf.Len()
+	fmt.Fprintf(u.buf, "%d 0 obj\n%s\nendobj\n\n", id, content)
+}
+
+// objectDict 返回编号为 id 的对象的字典内容（不包含最外层的 << >>），对象被之前的增量更新改写过时返回最后一次改写的内容。
+func (u *pdfUpdate) objectDict(id int) ([]byte, error) {
+	header := []byte(fmt.Sprintf("\n%d 0 obj\n", id))
+	start := bytes.LastIndex(u.data, header)
+	if 0 > start {
+		return nil, fmt.Errorf("pdf object [%d] not found", id)
+	}
+	start += len(header)
+	end := bytes.Index(u.data[start:], []byte("endobj"))
+	if 0 > end {
+		return nil, fmt.Errorf("pdf object [%d] is not closed", id)
+	}
+	obj := bytes.TrimSpace(u.data[start : start+end])
+	if !bytes.HasPrefix(obj, []byte("<<")) || !bytes.HasSuffix(obj, []byte(">>")) {
+		return nil, fmt.Errorf("pdf object [%d] is not a dictionary", id)
+	}
+	return append([]byte{}, obj[2:len(obj)-2]...), nil
+}
+
+// bytes 写入新的交叉引用表和 trailer，返回更新后的文档。
+func (u *pdfUpdate) bytes() []byte {
+	xrefOffset := u.buf.Len()
+	u.buf.WriteString("xref\n0 1\n0000000000 65535 f \n")
+	for id := 1; id < u.nextID; id++ {
+		if offset, ok := u.offsets[id]; ok {
+			fmt.Fprintf(u.buf, "%d 1\n%010d 00000 n \n", id, offset)
+		}
+	}
+	fmt.Fprintf(u.buf, "trailer\n<<\n/Size %d\n/Root %d 0 R\n/Prev %s\n>>\nstartxref\n%d\n%%%%EOF\n", u.nextID, u.root, u.prev, xrefOffset)
+	return u.buf.Bytes()
+}